@@ -0,0 +1,247 @@
+package freeboard
+
+import (
+	"sync"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// WidgetState is what BaseWidget passes to its Draw hook.
+type WidgetState struct {
+	// Settings are the widget's current settings.
+	Settings *js.Object
+	// Values are the latest calculated values, by setting name.
+	// This is a copy, and may be kept or changed freely.
+	Values map[string]interface{}
+	// Container is the element given to Render.
+	Container dom.HTMLElement
+}
+
+// BaseWidget does the bookkeeping common to most widgets, and implements
+// WidgetPlugin. Embed a *BaseWidget made by NewBaseWidget in a widget type
+// and supply a Draw hook; the hook is called with the current state on any
+// change, at most once per animation frame, once the widget is rendered.
+//
+//	type MyWidget struct{ *freeboard.BaseWidget }
+//
+//	func (mw *MyWidget) Draw(state freeboard.WidgetState) { ... }
+//
+//	NewInstance: func(settings *js.Object) freeboard.WidgetPlugin {
+//		mw := new(MyWidget)
+//		mw.BaseWidget = freeboard.NewBaseWidget(settings, mw.Draw)
+//		return mw
+//	}
+//
+// Widgets that need more than this can override any WidgetPlugin method,
+// calling the BaseWidget's method from their own to keep the bookkeeping.
+type BaseWidget struct {
+	mu        sync.Mutex
+	draw      func(WidgetState)
+	settings  *js.Object
+	values    map[string]interface{}
+	container dom.HTMLElement
+	height    int
+	scheduled bool
+	disposed  bool
+	errs      []error
+	// guard recovers panics in draw, which is called from outside the
+	// functions guarded by WrapWidgetPlugin. Once wrapped, it is the
+	// wrapper's guard, named for the concrete widget.
+	guard *pluginGuard
+}
+
+// NewBaseWidget returns a BaseWidget with the settings given to
+// NewInstance, which calls draw when anything changes.
+func NewBaseWidget(settings *js.Object, draw func(WidgetState)) *BaseWidget {
+	guard := newPluginGuard("BaseWidget")
+	guard.onPanic = append(guard.onPanic, guard.showInContainer)
+	return &BaseWidget{
+		draw:     draw,
+		settings: settings,
+		values:   make(map[string]interface{}),
+		height:   1,
+		guard:    guard,
+	}
+}
+
+// baseWidget returns the BaseWidget, for widgets that embed one.
+func (bw *BaseWidget) baseWidget() *BaseWidget {
+	return bw
+}
+
+// useGuard makes draw panics go through guard, the guard of the wrapper
+// around the widget embedding bw.
+func (bw *BaseWidget) useGuard(guard *pluginGuard) {
+	bw.mu.Lock()
+	bw.guard = guard
+	bw.mu.Unlock()
+}
+
+// Settings returns the current settings.
+func (bw *BaseWidget) Settings() *js.Object {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.settings
+}
+
+// Value returns the latest calculated value of the named setting,
+// or nil if there isn't one yet.
+func (bw *BaseWidget) Value(settingName string) interface{} {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.values[settingName]
+}
+
+// Container returns the element given to Render, or nil before then.
+func (bw *BaseWidget) Container() dom.HTMLElement {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.container
+}
+
+// SetHeight sets the number of 45-pixel blocks returned by GetHeight.
+// The default is 1. If the widget has been rendered and the height has
+// changed, freeboard is asked to lay out its pane again.
+func (bw *BaseWidget) SetHeight(blocks int) {
+	bw.mu.Lock()
+	changed := bw.height != blocks
+	bw.height = blocks
+	container := bw.container
+	bw.mu.Unlock()
+	if changed && container != nil {
+		UpdateWidgetHeight(container)
+	}
+}
+
+// OnSettingsChanged satisfies the WidgetPlugin interface.
+func (bw *BaseWidget) OnSettingsChanged(settings *js.Object) {
+	bw.mu.Lock()
+	bw.settings = settings
+	bw.mu.Unlock()
+	bw.Redraw()
+}
+
+// OnCalculatedValueChanged satisfies the WidgetPlugin interface.
+func (bw *BaseWidget) OnCalculatedValueChanged(settingName string, newValue interface{}) {
+	bw.mu.Lock()
+	bw.values[settingName] = newValue
+	bw.mu.Unlock()
+	bw.Redraw()
+}
+
+// Render satisfies the WidgetPlugin interface.
+func (bw *BaseWidget) Render(containerElement dom.HTMLElement) {
+	bw.mu.Lock()
+	bw.container = containerElement
+	bw.mu.Unlock()
+	bw.Redraw()
+}
+
+// GetHeight satisfies the WidgetPlugin interface.
+func (bw *BaseWidget) GetHeight() int {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.height
+}
+
+// OnSizeChanged satisfies the ResizableWidget interface, redrawing
+// so that the Draw hook can lay out to the new size.
+func (bw *BaseWidget) OnSizeChanged() {
+	bw.Redraw()
+}
+
+// OnDispose satisfies the WidgetPlugin interface. No draws happen
+// after it is called.
+func (bw *BaseWidget) OnDispose() {
+	bw.mu.Lock()
+	bw.disposed = true
+	bw.mu.Unlock()
+}
+
+// SetErrors sets problems found in the settings, such as rules that
+// don't parse, to be shown below whatever the Draw hook renders until
+// they are replaced. Pass nil to clear them.
+func (bw *BaseWidget) SetErrors(errs []error) {
+	bw.mu.Lock()
+	bw.errs = errs
+	bw.mu.Unlock()
+	bw.Redraw()
+}
+
+// Redraw schedules a call of the Draw hook for the next animation frame.
+// Calls made before then are merged into the one draw.
+func (bw *BaseWidget) Redraw() {
+	bw.mu.Lock()
+	if bw.scheduled || bw.disposed {
+		bw.mu.Unlock()
+		return
+	}
+	bw.scheduled = true
+	bw.mu.Unlock()
+	nextFrame(bw.drawNow)
+}
+
+// drawNow calls the Draw hook with the current state.
+func (bw *BaseWidget) drawNow() {
+	bw.mu.Lock()
+	bw.scheduled = false
+	if bw.disposed || bw.container == nil || bw.draw == nil {
+		bw.mu.Unlock()
+		return
+	}
+	state := WidgetState{
+		Settings:  bw.settings,
+		Values:    make(map[string]interface{}, len(bw.values)),
+		Container: bw.container,
+	}
+	for k, v := range bw.values {
+		state.Values[k] = v
+	}
+	guard, errs := bw.guard, bw.errs
+	bw.mu.Unlock()
+	guard.Lock()
+	guard.container = state.Container.Underlying()
+	guard.Unlock()
+	defer guard.recover("Draw")
+	bw.draw(state)
+	showErrors(state.Container, errs)
+}
+
+// settingsErrorsClass marks the list shown by showErrors, so that it's
+// replaced rather than added to by the next draw.
+const settingsErrorsClass = "go-freeboard-settings-errors"
+
+// showErrors appends a list of errors to a widget's container, styled as
+// the guard shows panics, in place of the list from the last draw. Draw
+// hooks that update their content in place leave the old list there.
+func showErrors(container dom.HTMLElement, errs []error) {
+	for _, n := range container.ChildNodes() {
+		if el, ok := n.(dom.Element); ok && el.Class().Contains(settingsErrorsClass) {
+			container.RemoveChild(el)
+		}
+	}
+	if len(errs) == 0 {
+		return
+	}
+	box := dom.GetWindow().Document().CreateElement("div").(dom.HTMLElement)
+	box.Class().SetString("go-freeboard-error " + settingsErrorsClass)
+	box.Style().SetProperty("color", "#ff6b6b", "")
+	box.Style().SetProperty("overflow", "hidden", "")
+	for _, err := range errs {
+		line := dom.GetWindow().Document().CreateElement("div")
+		line.SetTextContent("⚠ " + err.Error())
+		box.AppendChild(line)
+	}
+	container.AppendChild(box)
+}
+
+// nextFrame calls fn at the next animation frame, or as soon as possible
+// where there are no animation frames.
+func nextFrame(fn func()) {
+	if raf := js.Global.Get("requestAnimationFrame"); raf != js.Undefined && raf != nil {
+		js.Global.Call("requestAnimationFrame", fn)
+		return
+	}
+	js.Global.Call("setTimeout", fn, 0)
+}
@@ -0,0 +1,56 @@
+//go:build js
+// +build js
+
+package freeboard
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// fakeDocument installs a document with just enough of the DOM for
+// showErrors, and returns a func that removes it again.
+func fakeDocument() func() {
+	js.Global.Call("eval", `global.document = {
+		createElement: function() {
+			var el = {className: "", textContent: "", childNodes: [], style: {setProperty: function() {}}};
+			el.classList = {contains: function(c) { return el.className.split(" ").indexOf(c) >= 0; }};
+			el.appendChild = function(child) { el.childNodes.push(child); };
+			el.removeChild = function(child) { el.childNodes.splice(el.childNodes.indexOf(child), 1); };
+			return el;
+		}
+	};`)
+	return func() { js.Global.Call("eval", "delete global.document") }
+}
+
+func TestShowErrorsReplacesTheList(t *testing.T) {
+	defer fakeDocument()()
+	container := dom.GetWindow().Document().CreateElement("div").(dom.HTMLElement)
+	draws := 0
+	bw := NewBaseWidget(js.Global.Get("Object").New(), func(WidgetState) { draws++ })
+	bw.container = container
+	bw.errs = []error{errors.New("row 1: from is above to")}
+
+	boxes := func() int {
+		n := 0
+		for _, child := range container.ChildNodes() {
+			if child.(dom.Element).Class().Contains(settingsErrorsClass) {
+				n++
+			}
+		}
+		return n
+	}
+	bw.drawNow()
+	bw.drawNow()
+	if draws != 2 || boxes() != 1 {
+		t.Errorf("after %d draws there are %d error lists, want 1", draws, boxes())
+	}
+	bw.errs = nil
+	bw.drawNow()
+	if boxes() != 0 {
+		t.Errorf("after the errors are cleared there are %d error lists", boxes())
+	}
+}
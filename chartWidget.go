@@ -0,0 +1,437 @@
+package freeboard
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// chartPalette colours the series of a chart, in order.
+var chartPalette = []string{"#ff9900", "#4fc3f7", "#8bc34a", "#e57373", "#ba68c8", "#ffd54f"}
+
+// Chart padding, in pixels, around the plot area when axes are shown.
+const (
+	chartPadLeft   = 44.0
+	chartPadRight  = 8.0
+	chartPadTop    = 8.0
+	chartPadBottom = 22.0
+)
+
+// chartPoint is one point of a series; x is a timestamp in JS
+// milliseconds for time series, or an index otherwise.
+type chartPoint struct {
+	x, y float64
+}
+
+// chartSeries is one named line.
+type chartSeries struct {
+	name   string
+	points []chartPoint
+}
+
+// chartData is the parsed value of a chart's series setting.
+type chartData struct {
+	series                 []chartSeries
+	isTime                 bool
+	minX, maxX, minY, maxY float64
+}
+
+// chartWidget is the instance type of ChartWidget.
+type chartWidget struct {
+	*BaseWidget
+	// built is the settings object the SVG skeleton was built for,
+	// and builtWidth the container width it was built at.
+	built      *js.Object
+	builtWidth float64
+	// data and the plot geometry are kept for the hover tooltip.
+	data                    chartData
+	left, top, plotW, plotH float64
+}
+
+// OnSettingsChanged satisfies the WidgetPlugin interface.
+func (cw *chartWidget) OnSettingsChanged(settings *js.Object) {
+	cw.SetHeight(chartWidgetHeight(settings))
+	cw.BaseWidget.OnSettingsChanged(settings)
+}
+
+// Draw builds the chart's skeleton when the settings or width change,
+// and otherwise replaces only the plotted lines and axis ticks.
+func (cw *chartWidget) Draw(state WidgetState) {
+	s := state.Settings
+	width := state.Container.GetBoundingClientRect().Width
+	if width <= 0 {
+		width = 300
+	}
+	height := float64(chartWidgetHeight(s)*45 - 20)
+	if settingString(s, "title") != "" {
+		height -= 25
+	}
+	axes := !settingBool(s, "sparkline")
+	cw.left, cw.top, cw.plotW, cw.plotH = 0, 2, width, height-4
+	if axes {
+		cw.left, cw.top = chartPadLeft, chartPadTop
+		cw.plotW = width - chartPadLeft - chartPadRight
+		cw.plotH = height - chartPadTop - chartPadBottom
+	}
+
+	if cw.built != s || cw.builtWidth != width || state.Container.QuerySelector(".gfb-chart") == nil {
+		cw.buildSkeleton(state.Container, s, width, height)
+		cw.built, cw.builtWidth = s, width
+	}
+
+	cw.data = parseChartData(state.Values["series"])
+	applyChartRange(&cw.data, s)
+	if plot := state.Container.QuerySelector(".gfb-chart-plot"); plot != nil {
+		plot.SetInnerHTML(cw.plotSVG(axes))
+	}
+}
+
+// buildSkeleton lays out the title, SVG, axis labels and tooltip, and
+// attaches the hover handlers.
+func (cw *chartWidget) buildSkeleton(container dom.HTMLElement, s *js.Object, width, height float64) {
+	var b bytes.Buffer
+	if title := settingString(s, "title"); title != "" {
+		fmt.Fprintf(&b, `<h2 class="section-title">%s</h2>`, html.EscapeString(title))
+	}
+	b.WriteString(`<div style="position: relative">`)
+	fmt.Fprintf(&b, `<svg class="gfb-chart" width="%g" height="%g">`, width, height)
+	b.WriteString(`<g class="gfb-chart-plot"></g>`)
+	if !settingBool(s, "sparkline") {
+		if label := settingString(s, "y_label"); label != "" {
+			fmt.Fprintf(&b, `<text x="10" y="%g" transform="rotate(-90 10 %g)" text-anchor="middle" font-size="10" fill="#8b8b8b">%s</text>`,
+				cw.top+cw.plotH/2, cw.top+cw.plotH/2, html.EscapeString(label))
+		}
+		if label := settingString(s, "x_label"); label != "" {
+			fmt.Fprintf(&b, `<text x="%g" y="%g" text-anchor="middle" font-size="10" fill="#8b8b8b">%s</text>`,
+				cw.left+cw.plotW/2, height-4, html.EscapeString(label))
+		}
+	}
+	fmt.Fprintf(&b, `<line class="gfb-chart-cursor" x1="0" x2="0" y1="%g" y2="%g" stroke="#8b8b8b" stroke-dasharray="2 2" visibility="hidden"/>`,
+		cw.top, cw.top+cw.plotH)
+	b.WriteString(`</svg>`)
+	b.WriteString(`<div class="gfb-chart-tooltip" style="position: absolute; display: none; pointer-events: none; ` +
+		`background: rgba(0,0,0,0.8); color: #d3d4d4; padding: 3px 6px; font-size: 11px; white-space: nowrap; z-index: 10"></div>`)
+	b.WriteString(`</div>`)
+	container.SetInnerHTML(b.String())
+
+	svg := container.QuerySelector(".gfb-chart")
+	svg.AddEventListener("mousemove", false, func(ev dom.Event) {
+		cw.hover(container, ev.(*dom.MouseEvent))
+	})
+	svg.AddEventListener("mouseleave", false, func(dom.Event) {
+		cw.hideTooltip(container)
+	})
+}
+
+// hover shows the values nearest the pointer in the tooltip.
+func (cw *chartWidget) hover(container dom.HTMLElement, ev *dom.MouseEvent) {
+	svg := container.QuerySelector(".gfb-chart")
+	tooltip, _ := container.QuerySelector(".gfb-chart-tooltip").(dom.HTMLElement)
+	cursor := container.QuerySelector(".gfb-chart-cursor")
+	d := cw.data
+	if svg == nil || tooltip == nil || cursor == nil || len(d.series) == 0 || d.maxX <= d.minX {
+		return
+	}
+	px := float64(ev.ClientX) - svg.GetBoundingClientRect().Left
+	x := d.minX + (px-cw.left)/cw.plotW*(d.maxX-d.minX)
+	var lines []string
+	nearestX := math.NaN()
+	for i, series := range d.series {
+		p, ok := nearestPoint(series.points, x)
+		if !ok {
+			continue
+		}
+		if math.IsNaN(nearestX) {
+			nearestX = p.x
+			lines = append(lines, html.EscapeString(formatChartX(p.x, d.isTime)))
+		}
+		lines = append(lines, fmt.Sprintf(`<span style="color: %s">●</span> %s: %s`,
+			chartPalette[i%len(chartPalette)], html.EscapeString(series.name), html.EscapeString(FormatNumber(p.y, -1, false))))
+	}
+	if math.IsNaN(nearestX) {
+		cw.hideTooltip(container)
+		return
+	}
+	cx := cw.left + (nearestX-d.minX)/(d.maxX-d.minX)*cw.plotW
+	cursor.SetAttribute("x1", fmt.Sprint(cx))
+	cursor.SetAttribute("x2", fmt.Sprint(cx))
+	cursor.SetAttribute("visibility", "visible")
+	tooltip.SetInnerHTML(strings.Join(lines, "<br>"))
+	tooltip.Style().SetProperty("display", "block", "")
+	left := cx + 8
+	if cx > cw.left+cw.plotW/2 {
+		left = cx - 8 - tooltip.OffsetWidth()
+	}
+	tooltip.Style().SetProperty("left", fmt.Sprintf("%gpx", left), "")
+	tooltip.Style().SetProperty("top", fmt.Sprintf("%gpx", cw.top), "")
+}
+
+// hideTooltip hides the tooltip and cursor line.
+func (cw *chartWidget) hideTooltip(container dom.HTMLElement) {
+	if tooltip, ok := container.QuerySelector(".gfb-chart-tooltip").(dom.HTMLElement); ok {
+		tooltip.Style().SetProperty("display", "none", "")
+	}
+	if cursor := container.QuerySelector(".gfb-chart-cursor"); cursor != nil {
+		cursor.SetAttribute("visibility", "hidden")
+	}
+}
+
+// plotSVG draws the series and, if axes is set, the axes and ticks.
+func (cw *chartWidget) plotSVG(axes bool) string {
+	var b bytes.Buffer
+	d := cw.data
+	sx := func(x float64) float64 {
+		if d.maxX <= d.minX {
+			return cw.left + cw.plotW/2
+		}
+		return cw.left + (x-d.minX)/(d.maxX-d.minX)*cw.plotW
+	}
+	sy := func(y float64) float64 {
+		if d.maxY <= d.minY {
+			return cw.top + cw.plotH/2
+		}
+		return cw.top + cw.plotH - (y-d.minY)/(d.maxY-d.minY)*cw.plotH
+	}
+	if axes {
+		fmt.Fprintf(&b, `<path d="M %g %g V %g H %g" fill="none" stroke="#3d3d3d"/>`,
+			cw.left, cw.top, cw.top+cw.plotH, cw.left+cw.plotW)
+		if len(d.series) > 0 {
+			for i := 0; i <= 2; i++ {
+				y := d.minY + float64(i)/2*(d.maxY-d.minY)
+				fmt.Fprintf(&b, `<text x="%g" y="%g" text-anchor="end" font-size="10" fill="#8b8b8b">%s</text>`,
+					cw.left-4, sy(y)+3, html.EscapeString(FormatNumber(y, -1, true)))
+			}
+			fmt.Fprintf(&b, `<text x="%g" y="%g" text-anchor="start" font-size="10" fill="#8b8b8b">%s</text>`,
+				cw.left, cw.top+cw.plotH+12, html.EscapeString(formatChartX(d.minX, d.isTime)))
+			fmt.Fprintf(&b, `<text x="%g" y="%g" text-anchor="end" font-size="10" fill="#8b8b8b">%s</text>`,
+				cw.left+cw.plotW, cw.top+cw.plotH+12, html.EscapeString(formatChartX(d.maxX, d.isTime)))
+		}
+	}
+	for i, series := range d.series {
+		if len(series.points) == 0 {
+			continue
+		}
+		var path bytes.Buffer
+		for j, p := range series.points {
+			cmd := "L"
+			if j == 0 {
+				cmd = "M"
+			}
+			fmt.Fprintf(&path, "%s %.1f %.1f ", cmd, sx(p.x), sy(p.y))
+		}
+		fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-linejoin="round"/>`,
+			strings.TrimSpace(path.String()), chartPalette[i%len(chartPalette)])
+	}
+	return b.String()
+}
+
+// parseChartData reads a calculated value as chart series. It accepts an
+// array of numbers, an array of [timestamp, value] pairs, or an object
+// whose keys name several such arrays. Timestamps may be JS milliseconds,
+// dates or date strings. Points that can't be read are skipped.
+func parseChartData(v interface{}) chartData {
+	var d chartData
+	switch val := v.(type) {
+	case []interface{}:
+		d.series = []chartSeries{parseChartSeries("value", val, &d.isTime)}
+	case map[string]interface{}:
+		names := make([]string, 0, len(val))
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if arr, ok := val[name].([]interface{}); ok {
+				d.series = append(d.series, parseChartSeries(name, arr, &d.isTime))
+			}
+		}
+	}
+	d.minX, d.minY = math.Inf(1), math.Inf(1)
+	d.maxX, d.maxY = math.Inf(-1), math.Inf(-1)
+	for _, series := range d.series {
+		for _, p := range series.points {
+			d.minX, d.maxX = math.Min(d.minX, p.x), math.Max(d.maxX, p.x)
+			d.minY, d.maxY = math.Min(d.minY, p.y), math.Max(d.maxY, p.y)
+		}
+	}
+	if math.IsInf(d.minX, 1) {
+		d.minX, d.maxX, d.minY, d.maxY = 0, 0, 0, 0
+	}
+	return d
+}
+
+// parseChartSeries reads one array of numbers or pairs. isTime is set
+// if any x value is a date.
+func parseChartSeries(name string, arr []interface{}, isTime *bool) chartSeries {
+	series := chartSeries{name: name, points: make([]chartPoint, 0, len(arr))}
+	for i, el := range arr {
+		if y, ok := toFloat(el); ok {
+			series.points = append(series.points, chartPoint{float64(i), y})
+			continue
+		}
+		pair, ok := el.([]interface{})
+		if !ok || len(pair) < 2 {
+			continue
+		}
+		y, ok := toFloat(pair[1])
+		if !ok {
+			continue
+		}
+		x, date, ok := chartX(pair[0])
+		if !ok {
+			continue
+		}
+		if date {
+			*isTime = true
+		}
+		series.points = append(series.points, chartPoint{x, y})
+	}
+	sort.Sort(byChartX(series.points))
+	return series
+}
+
+// chartX reads an x value, reporting whether it was a date.
+func chartX(v interface{}) (x float64, date bool, ok bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return float64(t.UnixNano()) / float64(time.Millisecond), true, true
+	case float64:
+		// Numbers past 2001-09-09 in milliseconds are taken as timestamps.
+		return t, t > 1e12, true
+	case string:
+		if f, ok := toFloat(t); ok {
+			return f, f > 1e12, true
+		}
+		ms := js.Global.Get("Date").Call("parse", t).Float()
+		return ms, true, !math.IsNaN(ms)
+	}
+	return 0, false, false
+}
+
+// applyChartRange pads the automatic y range by 5%, and applies the
+// y_min and y_max settings where they're given.
+func applyChartRange(d *chartData, s *js.Object) {
+	pad := (d.maxY - d.minY) * 0.05
+	if pad == 0 {
+		pad = math.Max(math.Abs(d.maxY)*0.05, 1)
+	}
+	d.minY -= pad
+	d.maxY += pad
+	if settingString(s, "y_min") != "" {
+		d.minY = settingFloat(s, "y_min", d.minY)
+	}
+	if settingString(s, "y_max") != "" {
+		d.maxY = settingFloat(s, "y_max", d.maxY)
+	}
+}
+
+// nearestPoint finds the point of a sorted series nearest to x.
+func nearestPoint(points []chartPoint, x float64) (chartPoint, bool) {
+	if len(points) == 0 {
+		return chartPoint{}, false
+	}
+	i := sort.Search(len(points), func(i int) bool { return points[i].x >= x })
+	if i == len(points) {
+		return points[i-1], true
+	}
+	if i > 0 && x-points[i-1].x < points[i].x-x {
+		return points[i-1], true
+	}
+	return points[i], true
+}
+
+// formatChartX labels an x value as a local time or a number.
+func formatChartX(x float64, isTime bool) string {
+	if !isTime {
+		return FormatNumber(x, -1, false)
+	}
+	t := time.Unix(0, int64(x*float64(time.Millisecond)))
+	if t.YearDay() == time.Now().YearDay() && t.Year() == time.Now().Year() {
+		return t.Format("15:04:05")
+	}
+	return t.Format("2 Jan 15:04")
+}
+
+type byChartX []chartPoint
+
+func (s byChartX) Len() int           { return len(s) }
+func (s byChartX) Less(i, j int) bool { return s[i].x < s[j].x }
+func (s byChartX) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// chartWidgetHeight reads the height setting, defaulting to 3 blocks.
+func chartWidgetHeight(settings *js.Object) int {
+	if h := int(settingFloat(settings, "height", 3)); h > 0 {
+		return h
+	}
+	return 3
+}
+
+// ChartWidget draws one or more series as an SVG line chart, or as a bare
+// sparkline, with hover tooltips. Its series setting should evaluate to an
+// array of numbers, an array of [timestamp, value] pairs, or an object
+// whose keys name several such arrays.
+var ChartWidget = WtPluginDefinition{
+	TypeName:    "gofreeboard_chart",
+	DisplayName: "Line Chart (Go)",
+	Description: "Line chart or sparkline of one or more series, drawn as SVG.",
+	Settings: []FBSetting{
+		FBSetting{
+			Name:        "title",
+			DisplayName: "Title",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "series",
+			DisplayName: "Series",
+			Description: "An array of numbers or of [timestamp, value] pairs, or an object of such arrays keyed by series name.",
+			Type:        SettingCalculatedType,
+		},
+		FBSetting{
+			Name:        "sparkline",
+			DisplayName: "Sparkline",
+			Description: "Draw only the lines, with no axes.",
+			Type:        SettingBooleanType,
+		},
+		FBSetting{
+			Name:        "y_min",
+			DisplayName: "Y Minimum",
+			Description: "Leave blank to fit the data.",
+			Type:        SettingNumberType,
+		},
+		FBSetting{
+			Name:        "y_max",
+			DisplayName: "Y Maximum",
+			Description: "Leave blank to fit the data.",
+			Type:        SettingNumberType,
+		},
+		FBSetting{
+			Name:        "x_label",
+			DisplayName: "X Axis Label",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "y_label",
+			DisplayName: "Y Axis Label",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:            "height",
+			DisplayName:     "Height Blocks",
+			Type:            SettingNumberType,
+			DefaultIntValue: 3,
+		},
+	},
+	NewInstance: func(settings *js.Object) WidgetPlugin {
+		cw := new(chartWidget)
+		cw.BaseWidget = NewBaseWidget(settings, cw.Draw)
+		cw.SetHeight(chartWidgetHeight(settings))
+		return cw
+	},
+}
@@ -0,0 +1,91 @@
+// Command fblint checks freeboard dashboard files against the plugins
+// they use, for example before they're merged:
+//
+//	fblint -js-widgets my_widget dashboards/*.json
+//
+// It knows freeboard's own plugins and those defined by go-freeboard.
+// Other plugins, whether written in JS or Go, can be described in JSON
+// files given with -plugins, in the form read by PluginSet.AddDefinitions,
+// and their settings are checked like those of the built-in plugins:
+//
+//	fblint -plugins plugins.json dashboards/*.json
+//
+// Plugins can also just be named with -js-datasources and -js-widgets,
+// in which case their settings aren't checked.
+//
+// Each problem is printed as file: where: problem. The exit code is 1 if
+// any problems were found, and 2 if a file couldn't be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	freeboard "github.com/cathalgarvey/go-freeboard"
+)
+
+func main() {
+	jsDatasources := flag.String("js-datasources", "", "comma-separated `types` of other datasource plugins written in JS")
+	jsWidgets := flag.String("js-widgets", "", "comma-separated `types` of other widget plugins written in JS")
+	definitions := flag.String("plugins", "", "comma-separated JSON `files` defining other plugins")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: fblint [flags] dashboard.json...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	plugins := freeboard.NewPluginSet().AddFreeboard().AddBuiltin()
+	plugins.AddJS(splitList(*jsDatasources), splitList(*jsWidgets))
+	for _, path := range splitList(*definitions) {
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = plugins.AddDefinitions(data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			os.Exit(2)
+		}
+	}
+
+	exit := 0
+	for _, path := range flag.Args() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit = 2
+			continue
+		}
+		d, err := freeboard.ParseDashboard(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			exit = 2
+			continue
+		}
+		issues := freeboard.LintDashboard(d, plugins)
+		for _, issue := range issues {
+			fmt.Printf("%s: %s\n", path, issue)
+		}
+		if len(issues) > 0 && exit == 0 {
+			exit = 1
+		}
+	}
+	os.Exit(exit)
+}
+
+// splitList splits a comma-separated flag, dropping blanks.
+func splitList(s string) []string {
+	var list []string
+	for _, el := range strings.Split(s, ",") {
+		if el = strings.TrimSpace(el); el != "" {
+			list = append(list, el)
+		}
+	}
+	return list
+}
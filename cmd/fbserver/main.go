@@ -0,0 +1,33 @@
+// Command fbserver serves the dashboard API of the server package, and
+// optionally freeboard itself, for running locally:
+//
+//	go run ./cmd/fbserver -dir dashboards -static path/to/freeboard
+//
+// The API is served at /dashboards/.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/cathalgarvey/go-freeboard/server"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "`address` to listen on")
+	dir := flag.String("dir", "dashboards", "`directory` to store dashboards in")
+	static := flag.String("static", "", "`directory` of freeboard and plugin files to serve at /")
+	flag.Parse()
+
+	srv, err := server.New(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/dashboards/", http.StripPrefix("/dashboards", srv))
+	if *static != "" {
+		http.Handle("/", http.FileServer(http.Dir(*static)))
+	}
+	log.Printf("serving dashboards from %s on http://%s/dashboards/", *dir, *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
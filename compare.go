@@ -0,0 +1,72 @@
+package freeboard
+
+import "strings"
+
+// ComparisonHelp describes the operators understood by Compare, for use
+// in setting descriptions.
+const ComparisonHelp = "One of >, >=, <, <=, ==, != or contains; leave blank to always match."
+
+// Compare reports whether "v op operand" holds. If both v and operand
+// are numbers, or strings holding numbers, they are compared as numbers;
+// otherwise as strings. An empty op always matches, so that a rule with
+// no condition can serve as an "else". Unknown operators never match.
+func Compare(v interface{}, op, operand string) bool {
+	op = strings.TrimSpace(op)
+	if op == "" {
+		return true
+	}
+	if op == "contains" {
+		return strings.Contains(displayValue(v), operand)
+	}
+	a, aok := toFloat(v)
+	b, bok := toFloat(strings.TrimSpace(operand))
+	if aok && bok {
+		switch op {
+		case ">":
+			return a > b
+		case ">=":
+			return a >= b
+		case "<":
+			return a < b
+		case "<=":
+			return a <= b
+		case "==", "=":
+			return a == b
+		case "!=":
+			return a != b
+		}
+		return false
+	}
+	s := displayValue(v)
+	switch op {
+	case ">":
+		return s > operand
+	case ">=":
+		return s >= operand
+	case "<":
+		return s < operand
+	case "<=":
+		return s <= operand
+	case "==", "=":
+		return s == operand
+	case "!=":
+		return s != operand
+	}
+	return false
+}
+
+// lookupPath finds a value in nested objects by a dotted path, such as
+// "host.name". It returns nil if any step is missing.
+func lookupPath(v interface{}, path string) interface{} {
+	if path == "" {
+		return v
+	}
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
@@ -0,0 +1,155 @@
+package freeboard
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// DashboardVersion is the version of the serialised dashboard format
+// written by freeboard.
+const DashboardVersion = 1
+
+// Dashboard is a serialised freeboard dashboard, as returned by
+// freeboard.serialize and accepted by freeboard.loadDashboard. It
+// marshals to and from the same JSON with encoding/json, so it can be
+// read and written by programs outside the browser as well.
+type Dashboard struct {
+	Version     int    `json:"version"`
+	HeaderImage string `json:"header_image,omitempty"`
+	AllowEdit   bool   `json:"allow_edit"`
+	// Plugins are URLs of plugin scripts for freeboard to load.
+	Plugins     []string              `json:"plugins"`
+	Panes       []DashboardPane       `json:"panes"`
+	Datasources []DashboardDatasource `json:"datasources"`
+	// Columns is the number of columns the board was laid out for.
+	Columns int `json:"columns"`
+}
+
+// DashboardPane is a pane of a Dashboard, holding widgets.
+type DashboardPane struct {
+	Title string `json:"title"`
+	// Width is the pane's width in columns.
+	Width int `json:"width"`
+	// Row and Col hold the pane's position for each board width that it
+	// has been laid out at, keyed by the number of columns; see Position.
+	Row map[string]int `json:"row"`
+	Col map[string]int `json:"col"`
+	// ColWidth is the pane's width in columns, as freeboard 1.1 and
+	// later record it.
+	ColWidth int               `json:"col_width,omitempty"`
+	Widgets  []DashboardWidget `json:"widgets"`
+}
+
+// DashboardWidget is a widget within a DashboardPane.
+type DashboardWidget struct {
+	Title string `json:"title,omitempty"`
+	// Type is the widget plugin's TypeName.
+	Type     string                 `json:"type"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+// DashboardDatasource is a datasource of a Dashboard.
+type DashboardDatasource struct {
+	Name string `json:"name"`
+	// Type is the datasource plugin's TypeName.
+	Type     string                 `json:"type"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+// NewDashboard returns an empty, editable dashboard of the given number
+// of columns.
+func NewDashboard(columns int) *Dashboard {
+	return &Dashboard{
+		Version:     DashboardVersion,
+		AllowEdit:   true,
+		Plugins:     []string{},
+		Panes:       []DashboardPane{},
+		Datasources: []DashboardDatasource{},
+		Columns:     columns,
+	}
+}
+
+// ParseDashboard reads a dashboard from JSON. Lists and settings left
+// out are made empty rather than nil, so that the dashboard marshals as
+// freeboard expects even if the JSON was written by hand.
+func ParseDashboard(data []byte) (*Dashboard, error) {
+	d := new(Dashboard)
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, err
+	}
+	d.fillEmpty()
+	return d, nil
+}
+
+// fillEmpty replaces nil lists and maps with empty ones.
+func (d *Dashboard) fillEmpty() {
+	if d.Plugins == nil {
+		d.Plugins = []string{}
+	}
+	if d.Panes == nil {
+		d.Panes = []DashboardPane{}
+	}
+	if d.Datasources == nil {
+		d.Datasources = []DashboardDatasource{}
+	}
+	for i := range d.Datasources {
+		if d.Datasources[i].Settings == nil {
+			d.Datasources[i].Settings = make(map[string]interface{})
+		}
+	}
+	for i := range d.Panes {
+		pane := &d.Panes[i]
+		if pane.Row == nil {
+			pane.Row = make(map[string]int)
+		}
+		if pane.Col == nil {
+			pane.Col = make(map[string]int)
+		}
+		if pane.Widgets == nil {
+			pane.Widgets = []DashboardWidget{}
+		}
+		for j := range pane.Widgets {
+			if pane.Widgets[j].Settings == nil {
+				pane.Widgets[j].Settings = make(map[string]interface{})
+			}
+		}
+	}
+}
+
+// JSON marshals the dashboard, indented for reading.
+func (d *Dashboard) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "\t")
+}
+
+// Datasource returns the datasource with the given name, or nil.
+func (d *Dashboard) Datasource(name string) *DashboardDatasource {
+	for i := range d.Datasources {
+		if d.Datasources[i].Name == name {
+			return &d.Datasources[i]
+		}
+	}
+	return nil
+}
+
+// Position returns the pane's row and column when the board has the
+// given number of columns. ok is false if it has never been laid out at
+// that width, in which case freeboard places it in the first free spot.
+func (p *DashboardPane) Position(columns int) (row, col int, ok bool) {
+	key := strconv.Itoa(columns)
+	row, rowOK := p.Row[key]
+	col, colOK := p.Col[key]
+	return row, col, rowOK && colOK
+}
+
+// SetPosition places the pane at row and col, both counted from 1, when
+// the board has the given number of columns.
+func (p *DashboardPane) SetPosition(columns, row, col int) {
+	if p.Row == nil {
+		p.Row = make(map[string]int)
+	}
+	if p.Col == nil {
+		p.Col = make(map[string]int)
+	}
+	key := strconv.Itoa(columns)
+	p.Row[key], p.Col[key] = row, col
+}
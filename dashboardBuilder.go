@@ -0,0 +1,278 @@
+package freeboard
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DashboardErrors are the problems found with a dashboard.
+type DashboardErrors []error
+
+func (de DashboardErrors) Error() string {
+	msgs := make([]string, len(de))
+	for i, err := range de {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// DashboardBuilder builds a Dashboard, e.g.
+//
+//	d, err := freeboard.NewDashboardBuilder(3).
+//		DatasourceType(CatsDefinition).
+//		WidgetType(freeboard.TextWidget).
+//		Datasource("cats", "catsplugin", map[string]interface{}{"animal": "Tiger"}).
+//		Pane("Cats", 1).
+//		Widget("gofreeboard_text", map[string]interface{}{
+//			"title": "Favourite",
+//			"value": freeboard.Bind("cats", "catname"),
+//		}).
+//		Build()
+//
+// Plugin types must be made known to the builder before Build, which
+// checks that every widget and datasource is of a known type, and that
+// every datasource that widget settings refer to exists. Types made
+// known from Go definitions also give settings their default values.
+type DashboardBuilder struct {
+	dashboard *Dashboard
+	plugins   *PluginSet
+	errs      DashboardErrors
+}
+
+// NewDashboardBuilder starts an empty dashboard of the given number of
+// columns, knowing no plugin types.
+func NewDashboardBuilder(columns int) *DashboardBuilder {
+	return &DashboardBuilder{
+		dashboard: NewDashboard(columns),
+		plugins:   NewPluginSet(),
+	}
+}
+
+// DatasourceType makes Go datasource plugins known to the builder.
+func (b *DashboardBuilder) DatasourceType(defs ...DsPluginDefinition) *DashboardBuilder {
+	b.plugins.AddDatasources(defs...)
+	return b
+}
+
+// WidgetType makes Go widget plugins known to the builder.
+func (b *DashboardBuilder) WidgetType(defs ...WtPluginDefinition) *DashboardBuilder {
+	b.plugins.AddWidgets(defs...)
+	return b
+}
+
+// FreeboardTypes makes freeboard's own plugins known to the builder.
+func (b *DashboardBuilder) FreeboardTypes() *DashboardBuilder {
+	b.plugins.AddFreeboard()
+	return b
+}
+
+// Plugins makes every plugin in a PluginSet known to the builder.
+func (b *DashboardBuilder) Plugins(ps *PluginSet) *DashboardBuilder {
+	for t, schema := range ps.datasources {
+		b.plugins.datasources[t] = schema
+	}
+	for t, schema := range ps.widgets {
+		b.plugins.widgets[t] = schema
+	}
+	return b
+}
+
+// JSTypes makes plugins written in JS known to the builder, by TypeName.
+func (b *DashboardBuilder) JSTypes(datasourceTypes, widgetTypes []string) *DashboardBuilder {
+	b.plugins.AddJS(datasourceTypes, widgetTypes)
+	return b
+}
+
+// HeaderImage sets the URL of the image shown in the board's header.
+func (b *DashboardBuilder) HeaderImage(url string) *DashboardBuilder {
+	b.dashboard.HeaderImage = url
+	return b
+}
+
+// AllowEdit sets whether the board may be edited; it may by default.
+func (b *DashboardBuilder) AllowEdit(allow bool) *DashboardBuilder {
+	b.dashboard.AllowEdit = allow
+	return b
+}
+
+// Plugin adds the URL of a plugin script for freeboard to load.
+func (b *DashboardBuilder) Plugin(url string) *DashboardBuilder {
+	b.dashboard.Plugins = append(b.dashboard.Plugins, url)
+	return b
+}
+
+// Datasource adds a datasource. Settings may be nil.
+func (b *DashboardBuilder) Datasource(name, typeName string, settings map[string]interface{}) *DashboardBuilder {
+	if b.dashboard.Datasource(name) != nil {
+		b.errs = append(b.errs, errors.New("freeboard: duplicate datasource "+strconv.Quote(name)))
+		return b
+	}
+	b.dashboard.Datasources = append(b.dashboard.Datasources, DashboardDatasource{
+		Name:     name,
+		Type:     typeName,
+		Settings: copySettings(settings),
+	})
+	return b
+}
+
+// Pane adds a pane of the given width in columns. Widgets added after
+// it go in it, until the next pane.
+func (b *DashboardBuilder) Pane(title string, width int) *DashboardBuilder {
+	if width < 1 {
+		width = 1
+	}
+	b.dashboard.Panes = append(b.dashboard.Panes, DashboardPane{
+		Title:    title,
+		Width:    width,
+		ColWidth: width,
+		Row:      make(map[string]int),
+		Col:      make(map[string]int),
+		Widgets:  []DashboardWidget{},
+	})
+	return b
+}
+
+// At places the last pane added at row and col, both counted from 1.
+// Panes that aren't placed are laid out by freeboard.
+func (b *DashboardBuilder) At(row, col int) *DashboardBuilder {
+	if len(b.dashboard.Panes) == 0 {
+		b.errs = append(b.errs, errors.New("freeboard: At called before any Pane"))
+		return b
+	}
+	b.dashboard.Panes[len(b.dashboard.Panes)-1].SetPosition(b.dashboard.Columns, row, col)
+	return b
+}
+
+// Widget adds a widget to the last pane added. Settings may be nil; bind
+// calculated settings to datasources with Bind.
+func (b *DashboardBuilder) Widget(typeName string, settings map[string]interface{}) *DashboardBuilder {
+	if len(b.dashboard.Panes) == 0 {
+		b.errs = append(b.errs, errors.New("freeboard: widget "+strconv.Quote(typeName)+" added before any Pane"))
+		return b
+	}
+	pane := &b.dashboard.Panes[len(b.dashboard.Panes)-1]
+	pane.Widgets = append(pane.Widgets, DashboardWidget{
+		Type:     typeName,
+		Settings: copySettings(settings),
+	})
+	return b
+}
+
+// Build checks the dashboard and returns it. The error, if any, is a
+// DashboardErrors listing every problem found.
+func (b *DashboardBuilder) Build() (*Dashboard, error) {
+	errs := append(DashboardErrors(nil), b.errs...)
+	for i := range b.dashboard.Datasources {
+		ds := &b.dashboard.Datasources[i]
+		schema, ok := b.plugins.datasource(ds.Type)
+		if !ok {
+			errs = append(errs, errors.New("freeboard: datasource "+strconv.Quote(ds.Name)+" is of unknown type "+strconv.Quote(ds.Type)))
+		}
+		errs = append(errs, applyDefaults(ds.Settings, schema.settings)...)
+	}
+	for _, pane := range b.dashboard.Panes {
+		for _, w := range pane.Widgets {
+			schema, ok := b.plugins.widget(w.Type)
+			if !ok {
+				errs = append(errs, errors.New("freeboard: widget in pane "+strconv.Quote(pane.Title)+" is of unknown type "+strconv.Quote(w.Type)))
+			}
+			errs = append(errs, applyDefaults(w.Settings, schema.settings)...)
+			for _, name := range DatasourceRefs(w.Settings) {
+				if b.dashboard.Datasource(name) == nil {
+					errs = append(errs, errors.New("freeboard: "+w.Type+" widget in pane "+strconv.Quote(pane.Title)+" refers to missing datasource "+strconv.Quote(name)))
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return b.dashboard, errs
+	}
+	return b.dashboard, nil
+}
+
+// Bind returns a calculated setting's value that reads a field of a
+// datasource, e.g. Bind("weather", "current", "temp") gives
+// datasources["weather"]["current"]["temp"].
+func Bind(datasource string, path ...string) string {
+	expr := "datasources[" + strconv.Quote(datasource) + "]"
+	for _, p := range path {
+		expr += "[" + strconv.Quote(p) + "]"
+	}
+	return expr
+}
+
+// datasourceRefPattern matches datasources["name"], datasources['name']
+// and datasources.name in calculated settings.
+var datasourceRefPattern = regexp.MustCompile(`datasources(?:\[\s*"((?:[^"\\]|\\.)*)"\s*\]|\[\s*'([^']*)'\s*\]|\.([A-Za-z_$][A-Za-z0-9_$]*))`)
+
+// DatasourceRefs returns the names of the datasources referred to by
+// string settings, including those in array settings' rows, sorted and
+// without repeats.
+func DatasourceRefs(settings map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			for _, m := range datasourceRefPattern.FindAllStringSubmatch(v, -1) {
+				name := m[2] + m[3]
+				if m[1] != "" {
+					if s, err := strconv.Unquote(`"` + m[1] + `"`); err == nil {
+						name = s
+					} else {
+						name = m[1]
+					}
+				}
+				seen[name] = true
+			}
+		case []interface{}:
+			for _, el := range v {
+				walk(el)
+			}
+		case map[string]interface{}:
+			for _, el := range v {
+				walk(el)
+			}
+		}
+	}
+	walk(settings)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// copySettings copies a settings map, so the caller can reuse theirs.
+func copySettings(settings map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		c[k] = v
+	}
+	return c
+}
+
+// applyDefaults fills in settings that aren't given from the defaults
+// of their definitions, as freeboard does in its settings dialog. It
+// returns the errors of definitions whose defaults can't be read.
+func applyDefaults(settings map[string]interface{}, defs []FBSetting) []error {
+	var errs []error
+	for _, def := range defs {
+		if _, ok := settings[def.Name]; ok {
+			continue
+		}
+		v, ok, err := def.defaultValue()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			settings[def.Name] = v
+		}
+	}
+	return errs
+}
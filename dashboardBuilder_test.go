@@ -0,0 +1,146 @@
+package freeboard
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// builderDs and builderWt are plugins for the builder tests.
+var (
+	builderDs = DsPluginDefinition{
+		TypeName: "builder_ds",
+		Settings: []FBSetting{
+			FBSetting{Name: "refresh", Type: SettingNumberType, DefaultIntValue: 5},
+			FBSetting{Name: "url", Type: SettingTextType},
+		},
+	}
+	builderWt = WtPluginDefinition{
+		TypeName: "builder_wt",
+		Settings: []FBSetting{
+			FBSetting{Name: "title", Type: SettingTextType, DefaultStringValue: "Untitled"},
+			FBSetting{Name: "value", Type: SettingCalculatedType},
+		},
+	}
+	// builderBadWt has a setting whose default can't be read.
+	builderBadWt = WtPluginDefinition{
+		TypeName: "builder_bad_wt",
+		Settings: []FBSetting{
+			FBSetting{Name: "scale", Type: SettingNumberType, DefaultIntValue: 1, DefaultFloatValue: 0.5},
+		},
+	}
+)
+
+func TestDashboardBuilder(t *testing.T) {
+	d, err := NewDashboardBuilder(3).
+		DatasourceType(builderDs).
+		WidgetType(builderWt).
+		Datasource("feed", "builder_ds", map[string]interface{}{"url": "http://example.com"}).
+		Pane("Feed", 2).At(1, 2).
+		Widget("builder_wt", map[string]interface{}{"value": Bind("feed", "items", "0")}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := d.Datasource("feed")
+	if ds == nil || ds.Settings["refresh"] != 5 || ds.Settings["url"] != "http://example.com" {
+		t.Errorf("datasource settings are %v, want the default refresh and the url given", ds)
+	}
+	pane := d.Panes[0]
+	if row, col, ok := pane.Position(3); !ok || row != 1 || col != 2 || pane.Width != 2 || pane.ColWidth != 2 {
+		t.Errorf("pane is %+v", pane)
+	}
+	want := map[string]interface{}{"title": "Untitled", "value": `datasources["feed"]["items"]["0"]`}
+	if got := pane.Widgets[0].Settings; !reflect.DeepEqual(got, want) {
+		t.Errorf("widget settings are %v, want %v", got, want)
+	}
+}
+
+func TestDashboardBuilderErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		build func(b *DashboardBuilder) *DashboardBuilder
+		want  []string
+	}{
+		{
+			"unknown types",
+			func(b *DashboardBuilder) *DashboardBuilder {
+				return b.Datasource("a", "nope", nil).Pane("p", 1).Widget("nada", nil)
+			},
+			[]string{`datasource "a" is of unknown type "nope"`, `is of unknown type "nada"`},
+		},
+		{
+			"missing datasource",
+			func(b *DashboardBuilder) *DashboardBuilder {
+				return b.Pane("p", 1).Widget("builder_wt", map[string]interface{}{"value": `datasources.gone + datasources["also gone"].x`})
+			},
+			[]string{`missing datasource "also gone"`, `missing datasource "gone"`},
+		},
+		{
+			"duplicate datasource",
+			func(b *DashboardBuilder) *DashboardBuilder {
+				return b.Datasource("a", "builder_ds", nil).Datasource("a", "builder_ds", nil)
+			},
+			[]string{`duplicate datasource "a"`},
+		},
+		{
+			"no pane",
+			func(b *DashboardBuilder) *DashboardBuilder {
+				return b.At(1, 1).Widget("builder_wt", nil)
+			},
+			[]string{"At called before any Pane", `widget "builder_wt" added before any Pane`},
+		},
+		{
+			"unreadable default",
+			func(b *DashboardBuilder) *DashboardBuilder {
+				return b.WidgetType(builderBadWt).Pane("p", 1).Widget("builder_bad_wt", nil)
+			},
+			[]string{`setting "scale" has both int and float defaults`},
+		},
+		{
+			"freeboard and JS types",
+			func(b *DashboardBuilder) *DashboardBuilder {
+				return b.FreeboardTypes().JSTypes([]string{"js_ds"}, nil).
+					Datasource("clock", "clock", nil).Datasource("js", "js_ds", nil).
+					Pane("p", 1).Widget("text_widget", map[string]interface{}{"value": Bind("clock", "time")})
+			},
+			nil,
+		},
+	} {
+		b := NewDashboardBuilder(3).DatasourceType(builderDs).WidgetType(builderWt)
+		_, err := tc.build(b).Build()
+		if tc.want == nil {
+			if err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+			continue
+		}
+		errs, ok := err.(DashboardErrors)
+		if !ok || len(errs) != len(tc.want) {
+			t.Errorf("%s: got %v, want %d errors", tc.name, err, len(tc.want))
+			continue
+		}
+		for i, want := range tc.want {
+			if !strings.Contains(errs[i].Error(), want) {
+				t.Errorf("%s: error %d is %q, want it to mention %s", tc.name, i, errs[i], want)
+			}
+		}
+	}
+}
+
+func TestDatasourceRefs(t *testing.T) {
+	for _, tc := range []struct {
+		settings map[string]interface{}
+		want     []string
+	}{
+		{map[string]interface{}{"v": `datasources["a"]["b"]`}, []string{"a"}},
+		{map[string]interface{}{"v": `datasources['a b'] + datasources.c_d`}, []string{"a b", "c_d"}},
+		{map[string]interface{}{"v": `datasources["q\"uote"]`}, []string{`q"uote`}},
+		{map[string]interface{}{"rows": []interface{}{map[string]interface{}{"v": `datasources.x`}}, "n": 3.0}, []string{"x"}},
+		{map[string]interface{}{"v": "no references", "w": `datasources.b + datasources.a + datasources.b`}, []string{"a", "b"}},
+	} {
+		if got := DatasourceRefs(tc.settings); !reflect.DeepEqual(got, tc.want) && !(len(got) == 0 && len(tc.want) == 0) {
+			t.Errorf("DatasourceRefs(%v) = %q, want %q", tc.settings, got, tc.want)
+		}
+	}
+}
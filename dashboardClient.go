@@ -0,0 +1,209 @@
+package freeboard
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// DashboardInfo describes a dashboard stored on a server, as listed by
+// the server package.
+type DashboardInfo struct {
+	Name     string    `json:"name"`
+	ETag     string    `json:"etag"`
+	Modified time.Time `json:"modified"`
+}
+
+// ErrDashboardConflict is returned by DashboardClient.Save when the
+// dashboard was changed on the server since it was loaded.
+var ErrDashboardConflict = errors.New("freeboard: the dashboard has been changed by someone else")
+
+// ErrDashboardNotFound is returned when there's no such dashboard.
+var ErrDashboardNotFound = errors.New("freeboard: no such dashboard")
+
+// DashboardClient loads and saves boards through a server made with the
+// server package. It remembers the ETag of each dashboard it loads or
+// saves, so that Save fails with ErrDashboardConflict rather than
+// overwriting someone else's changes.
+//
+// Its methods block until the server replies, so call them from a
+// goroutine, not straight from a JS callback.
+type DashboardClient struct {
+	sync.Mutex
+	fb *FBWrapper
+	// BaseURL is where the server is mounted, e.g. "/dashboards".
+	BaseURL string
+	etags   map[string]string
+}
+
+// NewDashboardClient returns a client for the server at baseURL, which
+// loads into and saves from the board wrapped by fb.
+func NewDashboardClient(fb *FBWrapper, baseURL string) *DashboardClient {
+	return &DashboardClient{fb: fb, BaseURL: strings.TrimSuffix(baseURL, "/"), etags: make(map[string]string)}
+}
+
+// List returns the dashboards on the server.
+func (dc *DashboardClient) List() ([]DashboardInfo, error) {
+	resp, err := httpRequest("GET", dc.BaseURL+"/", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.err(); err != nil {
+		return nil, err
+	}
+	var infos []DashboardInfo
+	if err := json.Unmarshal([]byte(resp.body), &infos); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// Get fetches a dashboard without loading it.
+func (dc *DashboardClient) Get(name string) (*Dashboard, error) {
+	resp, err := httpRequest("GET", dc.url(name), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.err(); err != nil {
+		return nil, err
+	}
+	dc.setETag(name, resp.etag)
+	return ParseDashboard([]byte(resp.body))
+}
+
+// Load fetches a dashboard and loads it into the board.
+func (dc *DashboardClient) Load(name string) error {
+	d, err := dc.Get(name)
+	if err != nil {
+		return err
+	}
+	return dc.fb.LoadDashboardSync(d)
+}
+
+// Save stores the board on the server under name. It's created if this
+// client hasn't loaded or saved it before, and updated otherwise.
+func (dc *DashboardClient) Save(name string) error {
+	d, err := dc.fb.SerializeDashboard()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	dc.Lock()
+	tag := dc.etags[name]
+	dc.Unlock()
+	var resp *httpResponse
+	if tag == "" {
+		resp, err = httpRequest("POST", dc.url(name), data, "")
+	} else {
+		resp, err = httpRequest("PUT", dc.url(name), data, tag)
+	}
+	if err != nil {
+		return err
+	}
+	if err := resp.err(); err != nil {
+		return err
+	}
+	dc.setETag(name, resp.etag)
+	return nil
+}
+
+// Delete removes a dashboard from the server. If this client has loaded
+// or saved it, it fails with ErrDashboardConflict if it's changed since.
+func (dc *DashboardClient) Delete(name string) error {
+	dc.Lock()
+	tag := dc.etags[name]
+	dc.Unlock()
+	resp, err := httpRequest("DELETE", dc.url(name), nil, tag)
+	if err != nil {
+		return err
+	}
+	if err := resp.err(); err != nil {
+		return err
+	}
+	dc.setETag(name, "")
+	return nil
+}
+
+// Forget drops the remembered ETag of a dashboard, so that the next
+// Save of it overwrites whatever is on the server.
+func (dc *DashboardClient) Forget(name string) {
+	dc.setETag(name, "")
+}
+
+func (dc *DashboardClient) url(name string) string {
+	return dc.BaseURL + "/" + url.PathEscape(name)
+}
+
+func (dc *DashboardClient) setETag(name, tag string) {
+	dc.Lock()
+	defer dc.Unlock()
+	if tag == "" {
+		delete(dc.etags, name)
+	} else {
+		dc.etags[name] = tag
+	}
+}
+
+// httpResponse is what httpRequest needs of a response.
+type httpResponse struct {
+	status int
+	body   string
+	etag   string
+}
+
+// err returns an error for unsuccessful responses.
+func (hr *httpResponse) err() error {
+	switch {
+	case hr.status >= 200 && hr.status < 300:
+		return nil
+	case hr.status == 404:
+		return ErrDashboardNotFound
+	case hr.status == 409 || hr.status == 412:
+		return ErrDashboardConflict
+	}
+	return errors.New("freeboard: server replied " + strconv.Itoa(hr.status) + ": " + strings.TrimSpace(hr.body))
+}
+
+// httpRequest makes a request with XMLHttpRequest and waits for the
+// response. It's used rather than net/http, which adds a lot to the
+// compiled JS.
+func httpRequest(method, target string, body []byte, ifMatch string) (*httpResponse, error) {
+	type result struct {
+		resp *httpResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	xhr := js.Global.Get("XMLHttpRequest").New()
+	xhr.Call("open", method, target, true)
+	if ifMatch != "" {
+		xhr.Call("setRequestHeader", "If-Match", ifMatch)
+	}
+	xhr.Set("onload", func() {
+		etag := xhr.Call("getResponseHeader", "ETag")
+		resp := &httpResponse{status: xhr.Get("status").Int(), body: xhr.Get("responseText").String()}
+		if etag != nil && etag != js.Undefined {
+			resp.etag = etag.String()
+		}
+		done <- result{resp: resp}
+	})
+	xhr.Set("onerror", func() {
+		done <- result{err: errors.New("freeboard: " + method + " " + target + " failed")}
+	})
+	if body != nil {
+		xhr.Call("setRequestHeader", "Content-Type", "application/json")
+		xhr.Call("send", string(body))
+	} else {
+		xhr.Call("send")
+	}
+	r := <-done
+	return r.resp, r.err
+}
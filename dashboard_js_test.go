@@ -0,0 +1,48 @@
+//go:build js
+// +build js
+
+package freeboard
+
+import (
+	"testing"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// fakeFreeboard returns an object with freeboard's serialize and
+// loadDashboard, serving board and keeping what is loaded in loaded.
+func fakeFreeboard(board string, loaded *string) *js.Object {
+	fake := js.Global.Get("Object").New()
+	fake.Set("serialize", func() *js.Object {
+		return js.Global.Get("JSON").Call("parse", board)
+	})
+	fake.Set("loadDashboard", func(serialised, callback *js.Object) {
+		*loaded = js.Global.Get("JSON").Call("stringify", serialised).String()
+		if callback != nil && callback != js.Undefined {
+			callback.Invoke()
+		}
+	})
+	return fake
+}
+
+func TestSerializeAndLoadGoDashboard(t *testing.T) {
+	var loaded string
+	fb := &FBWrapper{FreeboardObject: fakeFreeboard(freeboardExport, &loaded)}
+	d, err := fb.SerializeDashboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Panes) != 2 || d.Panes[1].Widgets[0].Type != "catswidget" {
+		t.Fatalf("got %+v", d)
+	}
+	called := false
+	if err := fb.LoadGoDashboard(d, func() { called = true }); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("LoadGoDashboard's callback wasn't called")
+	}
+	if !sameJSON(t, []byte(freeboardExport), []byte(loaded)) {
+		t.Errorf("freeboard was given a different board back:\n%s", loaded)
+	}
+}
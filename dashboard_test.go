@@ -0,0 +1,154 @@
+package freeboard
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// freeboardExport is a board as saved by freeboard 1.1's "Save
+// Freeboard", with its own plugins, a Go plugin and a pane laid out at
+// two widths.
+const freeboardExport = `{
+	"version": 1,
+	"allow_edit": true,
+	"plugins": ["plugins/catsPlugin.js"],
+	"panes": [
+		{
+			"title": "Weather",
+			"width": 1,
+			"row": {"3": 1, "4": 1},
+			"col": {"3": 1, "4": 2},
+			"col_width": 2,
+			"widgets": [
+				{
+					"type": "text_widget",
+					"settings": {
+						"title": "Temperature",
+						"size": "regular",
+						"value": "datasources[\"weather\"][\"temp\"]",
+						"sparkline": true,
+						"animate": true,
+						"units": "°C"
+					}
+				},
+				{
+					"type": "gauge",
+					"settings": {
+						"title": "Humidity",
+						"value": "datasources[\"weather\"][\"humidity\"]",
+						"units": "%",
+						"min_value": 0,
+						"max_value": 100
+					}
+				}
+			]
+		},
+		{
+			"title": "",
+			"width": 1,
+			"row": {"3": 5},
+			"col": {"3": 1},
+			"col_width": 1,
+			"widgets": [
+				{
+					"title": "Cat",
+					"type": "catswidget",
+					"settings": {
+						"title": "Favourite",
+						"animal": "datasources[\"cats\"][\"catname\"]"
+					}
+				},
+				{
+					"type": "indicator",
+					"settings": {
+						"title": "Clock running",
+						"value": "datasources.clock.numeric_value > 0",
+						"on_text": "Yes",
+						"off_text": "No"
+					}
+				}
+			]
+		}
+	],
+	"datasources": [
+		{
+			"name": "weather",
+			"type": "openweathermap",
+			"settings": {"location": "Dublin, IE", "units": "metric", "refresh": 5}
+		},
+		{
+			"name": "clock",
+			"type": "clock",
+			"settings": {"refresh": 1}
+		},
+		{
+			"name": "cats",
+			"type": "catsplugin",
+			"settings": {"catname": "Fluffy", "animal": "Tiger", "refine": [{"attribute": "stripes", "value": "many"}]}
+		}
+	],
+	"columns": 3
+}`
+
+// sameJSON reports whether two JSON documents hold the same values.
+func sameJSON(t *testing.T, a, b []byte) bool {
+	var av, bv interface{}
+	if err := json.Unmarshal(a, &av); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &bv); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+func TestDashboardRoundTrip(t *testing.T) {
+	d, err := ParseDashboard([]byte(freeboardExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := d.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameJSON(t, []byte(freeboardExport), out) {
+		t.Errorf("the export changed on the way through Dashboard:\n%s", out)
+	}
+	if d.Columns != 3 || len(d.Panes) != 2 || len(d.Datasources) != 3 || d.Panes[0].ColWidth != 2 {
+		t.Errorf("got %+v", d)
+	}
+	if ds := d.Datasource("cats"); ds == nil || ds.Settings["animal"] != "Tiger" {
+		t.Errorf("Datasource(cats) = %+v", ds)
+	}
+	for _, tc := range []struct {
+		columns, row, col int
+		ok                bool
+	}{
+		{3, 1, 1, true},
+		{4, 1, 2, true},
+		{5, 0, 0, false},
+	} {
+		row, col, ok := d.Panes[0].Position(tc.columns)
+		if row != tc.row || col != tc.col || ok != tc.ok {
+			t.Errorf("Position(%d) = %d, %d, %v; want %d, %d, %v", tc.columns, row, col, ok, tc.row, tc.col, tc.ok)
+		}
+	}
+}
+
+func TestParseDashboardFillsEmpty(t *testing.T) {
+	d, err := ParseDashboard([]byte(`{"version": 1, "panes": [{"title": "p", "widgets": [{"type": "text_widget"}]}], "datasources": [{"name": "a", "type": "clock"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := d.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"version": 1, "allow_edit": false, "plugins": [], "columns": 0,
+		"panes": [{"title": "p", "width": 0, "row": {}, "col": {}, "widgets": [{"type": "text_widget", "settings": {}}]}],
+		"datasources": [{"name": "a", "type": "clock", "settings": {}}]}`
+	if !sameJSON(t, []byte(want), out) {
+		t.Errorf("got %s", out)
+	}
+}
@@ -0,0 +1,182 @@
+package freeboard
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// cacheKeyPrefix namespaces the last-value cache within localStorage.
+const cacheKeyPrefix = "go-freeboard:lastvalue:"
+
+// CachedFlag and CachedAtFlag are set on payloads replayed from the
+// last-value cache, so that widgets can tell stale values from live ones.
+// CachedAtFlag holds the time the payload was stored, in JS milliseconds.
+// Payloads that are not JS objects, such as numbers and arrays, are
+// replayed as they were, without the flags, so that widgets bound to
+// them see the same shape as they do live; every replayed payload, of
+// whatever kind, is marked by EventData.Cached on the
+// EventDatasourceUpdated it fires.
+const (
+	CachedFlag   = "_cached"
+	CachedAtFlag = "_cachedAt"
+)
+
+// localStorage returns the browser's localStorage, or nil if it is
+// unavailable (disabled, sandboxed, or not in a browser).
+func localStorage() (ls *js.Object) {
+	defer func() {
+		if recover() != nil {
+			ls = nil
+		}
+	}()
+	ls = js.Global.Get("localStorage")
+	if ls == js.Undefined || ls == nil {
+		return nil
+	}
+	return ls
+}
+
+// datasourceName finds the name freeboard has given the datasource of
+// type typeName whose settings are settings, by looking for it on the
+// serialised board. Freeboard doesn't pass names to plugins, so this is
+// the only way to learn it. Returns "" unless there's exactly one match:
+// before the datasource is added, if two datasources have the same
+// settings, or if there's no host.
+func datasourceName(host Host, typeName string, settings *js.Object) (name string) {
+	defer func() {
+		if recover() != nil {
+			name = ""
+		}
+	}()
+	if host == nil || settings == nil || settings == js.Undefined {
+		return ""
+	}
+	d, err := host.SerializeDashboard()
+	if err != nil {
+		return ""
+	}
+	var want interface{}
+	if err := json.Unmarshal([]byte(js.Global.Get("JSON").Call("stringify", settings).String()), &want); err != nil {
+		return ""
+	}
+	for _, ds := range d.Datasources {
+		if ds.Type != typeName || !reflect.DeepEqual(ds.Settings, want) {
+			continue
+		}
+		if name != "" {
+			return ""
+		}
+		name = ds.Name
+	}
+	return name
+}
+
+// valueCache keeps the last payload of a datasource instance in
+// localStorage, keyed by type and datasource name, and replays it
+// when the instance is recreated after a reload. The name is looked up
+// again after each settings change, since that's how freeboard renames
+// a datasource.
+type valueCache struct {
+	host     Host
+	typeName string
+	settings *js.Object
+	name     string
+	live     bool
+}
+
+func newValueCache(host Host, typeName string, settings *js.Object) *valueCache {
+	return &valueCache{host: host, typeName: typeName, settings: settings}
+}
+
+// key returns the localStorage key for this instance, or "" if the
+// datasource name can't be resolved yet.
+func (vc *valueCache) key() string {
+	if vc.name == "" {
+		vc.name = datasourceName(vc.host, vc.typeName, vc.settings)
+	}
+	if vc.name == "" {
+		return ""
+	}
+	return cacheKeyPrefix + vc.typeName + ":" + vc.name
+}
+
+// settingsChanged follows the instance's new settings object, and
+// forgets its name, which may have changed with it.
+func (vc *valueCache) settingsChanged(settings *js.Object) {
+	vc.settings = settings
+	vc.name = ""
+}
+
+// wrapUpdate returns an update callback that stores each payload before
+// passing it on to update. Errors are passed on but not stored.
+func (vc *valueCache) wrapUpdate(update func(interface{})) func(interface{}) {
+	return func(payload interface{}) {
+		if _, ok := payload.(error); !ok {
+			vc.live = true
+			vc.store(payload)
+		}
+		update(payload)
+	}
+}
+
+// store saves payload as JSON. Failures (quota, unserialisable payloads)
+// are ignored; the cache is only ever a convenience.
+func (vc *valueCache) store(payload interface{}) {
+	defer func() { recover() }()
+	ls := localStorage()
+	key := vc.key()
+	if ls == nil || key == "" {
+		return
+	}
+	entry := js.Global.Get("Object").New()
+	entry.Set("at", js.Global.Get("Date").Call("now"))
+	entry.Set("payload", payload)
+	ls.Call("setItem", key, js.Global.Get("JSON").Call("stringify", entry))
+}
+
+// load returns the cached payload, marked with CachedFlag and
+// CachedAtFlag if it's an object, or nil if nothing is cached.
+func (vc *valueCache) load() (payload *js.Object) {
+	defer func() {
+		if recover() != nil {
+			payload = nil
+		}
+	}()
+	ls := localStorage()
+	key := vc.key()
+	if ls == nil || key == "" {
+		return nil
+	}
+	raw := ls.Call("getItem", key)
+	if raw == nil || raw == js.Undefined {
+		return nil
+	}
+	entry := js.Global.Get("JSON").Call("parse", raw)
+	payload = entry.Get("payload")
+	if payload == js.Undefined {
+		return nil
+	}
+	if payload == nil || payload.Get("constructor") != js.Global.Get("Object") {
+		return payload
+	}
+	payload.Set(CachedFlag, true)
+	payload.Set(CachedAtFlag, entry.Get("at"))
+	return payload
+}
+
+// emitCached sends the cached payload to updateCallback, unless a live
+// update has already arrived, and then passes it to replayed. It should
+// be run as a goroutine after the instance is handed to freeboard, by
+// which time the datasource is registered on the board and its name can
+// be resolved.
+func (vc *valueCache) emitCached(updateCallback *js.Object, replayed func(*js.Object)) {
+	if vc.live {
+		return
+	}
+	if payload := vc.load(); payload != nil {
+		updateCallback.Invoke(payload)
+		replayed(payload)
+	}
+}
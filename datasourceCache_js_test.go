@@ -0,0 +1,57 @@
+//go:build js
+// +build js
+
+package freeboard
+
+import (
+	"testing"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+func TestReplayedPayloadsAreMarked(t *testing.T) {
+	defer fakeLocalStorage()()
+	di := newDsInstrument(nil, "cache_test", nil)
+	defer func() {
+		dsInstruments.Lock()
+		delete(dsInstruments.all, di)
+		dsInstruments.Unlock()
+	}()
+	var events []EventData
+	sub := SubscribeEvent(EventDatasourceUpdated, func(data EventData) { events = append(events, data) })
+	defer sub.Unsubscribe()
+
+	for _, tc := range []struct {
+		payload interface{}
+		flagged bool
+	}{
+		{42, false},
+		{"cats", false},
+		{[]interface{}{1, 2}, false},
+		{map[string]interface{}{"count": 42}, true},
+	} {
+		events = nil
+		// The name is resolved from the board in use; here it's given.
+		(&valueCache{typeName: "cache_test", name: "ds"}).wrapUpdate(func(interface{}) {})(tc.payload)
+
+		var sent *js.Object
+		update := js.MakeFunc(func(this *js.Object, args []*js.Object) interface{} {
+			sent = args[0]
+			return nil
+		})
+		(&valueCache{typeName: "cache_test", name: "ds"}).emitCached(update, di.recordReplay)
+		if sent == nil {
+			t.Errorf("%v wasn't replayed", tc.payload)
+			continue
+		}
+		if flagged := sent.Get(CachedFlag) != js.Undefined; flagged != tc.flagged {
+			t.Errorf("%v replayed with %s set %v, want %v", tc.payload, CachedFlag, flagged, tc.flagged)
+		}
+		if len(events) != 1 || !events[0].Cached || events[0].TypeName != "cache_test" {
+			t.Errorf("%v replayed with events %+v, want one marked as cached", tc.payload, events)
+		}
+	}
+	if m := di.snapshot(); m.Updates != 0 {
+		t.Errorf("replays were counted as %d updates", m.Updates)
+	}
+}
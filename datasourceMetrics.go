@@ -0,0 +1,344 @@
+package freeboard
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// DsMetrics is a snapshot of the statistics kept for one Go datasource
+// instance.
+type DsMetrics struct {
+	// TypeName of the plugin definition the instance was made from.
+	TypeName string
+	// Name of the datasource on the board, or a generated placeholder
+	// if freeboard hasn't yet given it one.
+	Name string
+	// Updates is the number of payloads sent to freeboard.
+	Updates int
+	// Errors is the number of errors reported, either by passing an error
+	// to updateCallback or by panicking in a plugin method.
+	Errors int
+	// Panics is the number of those errors that were panics.
+	Panics int
+	// Errored is set if the instance has panicked since its settings
+	// were last changed.
+	Errored bool
+	// LastError is the message of the most recent error.
+	LastError string
+	// LastUpdate is when the most recent payload was sent.
+	LastUpdate time.Time
+	// Latency is the time between the start of the most recent refresh,
+	// whether freeboard, a ticker or RequestUpdate asked for it, and the
+	// payload that followed it.
+	Latency time.Duration
+	// PayloadSize is the length of the most recent payload, as JSON.
+	PayloadSize int
+}
+
+// toFBPayload converts the snapshot into the form emitted by
+// MetricsDatasource.
+func (m DsMetrics) toFBPayload() map[string]interface{} {
+	lastUpdate := 0.0
+	if !m.LastUpdate.IsZero() {
+		lastUpdate = float64(m.LastUpdate.UnixNano()) / float64(time.Millisecond)
+	}
+	return map[string]interface{}{
+		"type":          m.TypeName,
+		"name":          m.Name,
+		"updates":       m.Updates,
+		"errors":        m.Errors,
+		"panics":        m.Panics,
+		"errored":       m.Errored,
+		"last_error":    m.LastError,
+		"last_update":   lastUpdate,
+		"latency_ms":    float64(m.Latency) / float64(time.Millisecond),
+		"payload_bytes": m.PayloadSize,
+	}
+}
+
+// dsInstruments holds the instruments of all live datasource instances.
+var dsInstruments = struct {
+	sync.Mutex
+	all    map[*dsInstrument]struct{}
+	serial int
+}{all: make(map[*dsInstrument]struct{})}
+
+// dsInstrument records DsMetrics for one datasource instance.
+type dsInstrument struct {
+	sync.Mutex
+	metrics  DsMetrics
+	id       int
+	host     Host
+	settings *js.Object
+	pending  time.Time
+	// guard is the instance's pluginGuard, if it has one.
+	guard *pluginGuard
+	// marked is the name the instance is marked as errored under in
+	// freeboard's datasource list, if it is.
+	marked string
+}
+
+// newDsInstrument registers and returns an instrument for a new instance.
+func newDsInstrument(host Host, typeName string, settings *js.Object) *dsInstrument {
+	dsInstruments.Lock()
+	defer dsInstruments.Unlock()
+	dsInstruments.serial++
+	di := &dsInstrument{
+		metrics:  DsMetrics{TypeName: typeName},
+		id:       dsInstruments.serial,
+		host:     host,
+		settings: settings,
+	}
+	dsInstruments.all[di] = struct{}{}
+	return di
+}
+
+// snapshot returns the current metrics, resolving the datasource name
+// if it isn't known yet.
+func (di *dsInstrument) snapshot() DsMetrics {
+	di.Lock()
+	defer di.Unlock()
+	if di.metrics.Name == "" {
+		di.metrics.Name = datasourceName(di.host, di.metrics.TypeName, di.settings)
+	}
+	m := di.metrics
+	if di.guard != nil {
+		m.Errored = di.guard.Errored()
+	}
+	if m.Name == "" {
+		m.Name = m.TypeName + "#" + strconv.Itoa(di.id)
+	}
+	return m
+}
+
+// recordError counts an error against the instance, and fires
+// EventDatasourceError.
+func (di *dsInstrument) recordError(err error) {
+	di.Lock()
+	di.metrics.Errors++
+	di.metrics.LastError = err.Error()
+	di.Unlock()
+	di.emit(EventDatasourceError, nil, err)
+}
+
+// recordPanic counts a recovered panic against the instance, as an
+// error and as a panic, and marks it in freeboard's datasource list.
+func (di *dsInstrument) recordPanic(err *PanicError) {
+	di.Lock()
+	di.metrics.Panics++
+	di.Unlock()
+	di.recordError(err)
+	name := di.snapshot().Name
+	markDatasource(name, err)
+	di.Lock()
+	di.marked = name
+	di.Unlock()
+}
+
+// emit fires an event about the instance, if anyone is subscribed.
+func (di *dsInstrument) emit(event Event, value interface{}, err error) {
+	di.emitData(EventData{Event: event, Value: value, Err: err})
+}
+
+// emitData fires data as an event about the instance, filling in the
+// instance's details, if anyone is subscribed.
+func (di *dsInstrument) emitData(data EventData) {
+	if !hasSubscribers(data.Event) {
+		return
+	}
+	m := di.snapshot()
+	data.Kind, data.TypeName, data.Name = PluginKindDatasource, m.TypeName, m.Name
+	emitEvent(data)
+}
+
+// recordReplay fires EventDatasourceUpdated, marked as Cached, for a
+// payload replayed from the last-value cache. It isn't counted as an
+// update.
+func (di *dsInstrument) recordReplay(payload *js.Object) {
+	di.emitData(EventData{Event: EventDatasourceUpdated, Value: payload.Interface(), Cached: true})
+}
+
+// wrapUpdate returns an update callback that records each payload
+// before passing it on to update. Payloads that are errors are counted
+// as errors, and passed on as they always have been.
+func (di *dsInstrument) wrapUpdate(update func(interface{})) func(interface{}) {
+	return func(payload interface{}) {
+		if err, ok := payload.(error); ok {
+			di.recordError(err)
+			update(payload)
+			return
+		}
+		size := 0
+		func() {
+			defer func() { recover() }()
+			size = js.Global.Get("JSON").Call("stringify", payload).Length()
+		}()
+		now := time.Now()
+		di.Lock()
+		di.metrics.Updates++
+		di.metrics.LastUpdate = now
+		di.metrics.PayloadSize = size
+		if !di.pending.IsZero() {
+			di.metrics.Latency = now.Sub(di.pending)
+			di.pending = time.Time{}
+		}
+		di.Unlock()
+		update(payload)
+		di.emit(EventDatasourceUpdated, payload, nil)
+	}
+}
+
+// updateStarted notes the start of a refresh, for Latency. The
+// instance's updateFlight calls it, so that refreshes asked for by
+// freeboard, by tickers and by RequestUpdate are all timed.
+func (di *dsInstrument) updateStarted() {
+	di.Lock()
+	di.pending = time.Now()
+	di.Unlock()
+}
+
+// instrument replaces the functions of a map made by WrapDsPlugin with
+// versions that note settings changes, which also clear the errored
+// mark, and unregister on dispose. Disposal fires EventPluginDisposed.
+func (di *dsInstrument) instrument(wrapper map[string]interface{}) {
+	onDispose := wrapper["onDispose"].(func())
+	onSettingsChanged := wrapper["onSettingsChanged"].(func(*js.Object))
+	wrapper["onSettingsChanged"] = func(settings *js.Object) {
+		di.Lock()
+		di.settings = settings
+		// A settings change may come with a new name.
+		di.metrics.Name = ""
+		marked := di.marked
+		di.marked = ""
+		di.Unlock()
+		onSettingsChanged(settings)
+		if marked != "" {
+			markDatasource(marked, nil)
+		}
+	}
+	wrapper["onDispose"] = func() {
+		dsInstruments.Lock()
+		delete(dsInstruments.all, di)
+		dsInstruments.Unlock()
+		onDispose()
+		di.emit(EventPluginDisposed, nil, nil)
+	}
+}
+
+// DatasourceMetrics returns a snapshot of the metrics of every live Go
+// datasource instance, ordered by name.
+func DatasourceMetrics() []DsMetrics {
+	dsInstruments.Lock()
+	instruments := make([]*dsInstrument, 0, len(dsInstruments.all))
+	for di := range dsInstruments.all {
+		instruments = append(instruments, di)
+	}
+	dsInstruments.Unlock()
+	out := make([]DsMetrics, 0, len(instruments))
+	for _, di := range instruments {
+		out = append(out, di.snapshot())
+	}
+	sort.Sort(byMetricsName(out))
+	return out
+}
+
+type byMetricsName []DsMetrics
+
+func (s byMetricsName) Len() int           { return len(s) }
+func (s byMetricsName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s byMetricsName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// metricsPlugin is the instance type of MetricsDatasource.
+type metricsPlugin struct {
+	settings          *js.Object
+	updateFunc        func(interface{})
+	closeToKillUpdate chan interface{}
+}
+
+// CurrentSettings satisfies the DsPlugin interface.
+func (mp *metricsPlugin) CurrentSettings() *js.Object {
+	return mp.settings
+}
+
+// OnSettingsChanged satisfies the DsPlugin interface.
+func (mp *metricsPlugin) OnSettingsChanged(settings *js.Object) {
+	mp.settings = settings
+	close(mp.closeToKillUpdate)
+	mp.closeToKillUpdate = MakeUpdateTicker(mp, metricsRefresh(settings))
+	RequestUpdate(mp)
+}
+
+// UpdateNow satisfies the DsPlugin interface.
+func (mp *metricsPlugin) UpdateNow() {
+	all := DatasourceMetrics()
+	byName := make(map[string]interface{}, len(all))
+	list := make([]interface{}, 0, len(all))
+	totalUpdates, totalErrors := 0, 0
+	for _, m := range all {
+		// The metrics datasources' own updates would only count
+		// themselves.
+		if m.TypeName == metricsTypeName {
+			continue
+		}
+		p := m.toFBPayload()
+		byName[m.Name] = p
+		list = append(list, p)
+		totalUpdates += m.Updates
+		totalErrors += m.Errors
+	}
+	mp.updateFunc(map[string]interface{}{
+		"datasources":   byName,
+		"list":          list,
+		"count":         len(list),
+		"total_updates": totalUpdates,
+		"total_errors":  totalErrors,
+	})
+}
+
+// OnDispose satisfies the DsPlugin interface.
+func (mp *metricsPlugin) OnDispose() {
+	close(mp.closeToKillUpdate)
+}
+
+// metricsRefresh reads the refresh setting, defaulting to 5 seconds.
+func metricsRefresh(settings *js.Object) int {
+	refresh := settings.Get("refresh")
+	if refresh == js.Undefined || refresh == nil || refresh.Int() < 1 {
+		return 5
+	}
+	return refresh.Int()
+}
+
+// metricsTypeName is the TypeName of MetricsDatasource.
+const metricsTypeName = "gofreeboard_metrics"
+
+// MetricsDatasource is a built-in datasource reporting DatasourceMetrics
+// for every other Go datasource on the board. Load it like any other plugin.
+// Its payload has "datasources", keyed by datasource name, and "list",
+// ordered by name; each entry has "type", "name", "updates", "errors",
+// "panics", "errored", "last_error", "last_update" (JS milliseconds),
+// "latency_ms" and "payload_bytes". Totals are given as "count", "total_updates" and
+// "total_errors".
+var MetricsDatasource = DsPluginDefinition{
+	TypeName:    metricsTypeName,
+	DisplayName: "Framework Metrics",
+	Description: "Update counts, errors, latency and payload sizes of the Go datasources on this board.",
+	Settings: []FBSetting{
+		FBSetting{
+			Name:            "refresh",
+			DisplayName:     "Refresh Every",
+			Description:     "Seconds between refreshes.",
+			Type:            SettingNumberType,
+			DefaultIntValue: 5,
+		},
+	},
+	NewInstance: func(settings *js.Object, updateCallback func(interface{})) DsPlugin {
+		mp := &metricsPlugin{settings: settings, updateFunc: updateCallback}
+		mp.closeToKillUpdate = MakeUpdateTicker(mp, metricsRefresh(settings))
+		return mp
+	},
+}
@@ -0,0 +1,55 @@
+package freeboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// slowPlugin is a DsPlugin that takes delay to produce each payload.
+type slowPlugin struct {
+	delay  time.Duration
+	update func(interface{})
+}
+
+func (sp *slowPlugin) OnSettingsChanged(*js.Object) {}
+func (sp *slowPlugin) OnDispose()                   {}
+func (sp *slowPlugin) CurrentSettings() *js.Object  { return nil }
+
+func (sp *slowPlugin) UpdateNow() {
+	time.Sleep(sp.delay)
+	sp.update("payload")
+}
+
+func TestLatencyOfTickerRefreshes(t *testing.T) {
+	// Wired as ToFBInterfaceFor wires a Go datasource.
+	guard := newPluginGuard("slowPlugin")
+	di := newDsInstrument(nil, "slow_test", nil)
+	defer func() {
+		dsInstruments.Lock()
+		delete(dsInstruments.all, di)
+		dsInstruments.Unlock()
+	}()
+	flight := newUpdateFlight(UpdateSerial, guard)
+	flight.started = di.updateStarted
+	sp := &slowPlugin{delay: 30 * time.Millisecond}
+	sp.update = flight.wrapUpdate(di.wrapUpdate(func(interface{}) {}))
+	wrapper := wrapDsPlugin(sp, flight, guard)
+	di.instrument(wrapper)
+	defer wrapper["onDispose"].(func())()
+
+	ticker := MakeUpdateTicker(sp, 1)
+	defer close(ticker)
+	deadline := time.Now().Add(3 * time.Second)
+	for di.snapshot().Updates == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the ticker didn't refresh the plugin")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Browser timers may fire a millisecond or so early.
+	if m := di.snapshot(); m.Latency < sp.delay/2 || m.Latency > time.Second {
+		t.Errorf("latency after a ticker refresh is %v, want about %v", m.Latency, sp.delay)
+	}
+}
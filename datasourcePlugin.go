@@ -1,6 +1,7 @@
 package freeboard
 
 import (
+	"reflect"
 	"time"
 
 	"github.com/gopherjs/gopherjs/js"
@@ -33,13 +34,32 @@ type DsPlugin interface {
 // characters in the JS style. Also present is "plugin", which
 // directly references the DsPlugin object.
 func WrapDsPlugin(dsp DsPlugin) map[string]interface{} {
-	return map[string]interface{}{
+	return WrapDsPluginPolicy(dsp, UpdateConcurrent)
+}
+
+// WrapDsPluginPolicy is WrapDsPlugin, but applies the given UpdatePolicy
+// to overlapping calls of UpdateNow, whether they come from freeboard or
+// from a ticker made by MakeUpdateTicker. New settings and disposal
+// cancel any refresh in flight; see CancellableDsPlugin.
+//
+// Panics in any of the wrapped methods are recovered and logged to
+// the console, so that one faulty plugin can't stop the board.
+func WrapDsPluginPolicy(dsp DsPlugin, policy UpdatePolicy) map[string]interface{} {
+	guard := newPluginGuard(reflect.TypeOf(dsp).String())
+	return wrapDsPlugin(dsp, newUpdateFlight(policy, guard), guard)
+}
+
+func wrapDsPlugin(dsp DsPlugin, uf *updateFlight, guard *pluginGuard) map[string]interface{} {
+	uf.attach(dsp)
+	wrapper := map[string]interface{}{
 		"plugin":            dsp,
-		"updateNow":         dsp.UpdateNow,
-		"onDispose":         dsp.OnDispose,
-		"onSettingsChanged": dsp.OnSettingsChanged,
+		"updateNow":         uf.UpdateNow,
+		"onDispose":         uf.OnDispose,
+		"onSettingsChanged": uf.OnSettingsChanged,
 		"currentSettings":   dsp.CurrentSettings,
 	}
+	guard.guardFuncs(wrapper)
+	return wrapper
 }
 
 // MakeUpdateTicker creates a goroutine that polls a DataSource's
@@ -48,19 +68,27 @@ func WrapDsPlugin(dsp DsPlugin) map[string]interface{} {
 // This is provided as a helper because of how common these update
 // tickers are in freeboard plugins. Just store the ticker channel
 // and close it in the OnDispose() method.
+// Once the plugin is wrapped, ticks go through the wrapper's
+// UpdatePolicy like any other call to UpdateNow.
 func MakeUpdateTicker(dsp DsPlugin, seconds int) chan interface{} {
-	closeToKillUpdate := make(chan interface{})
-	go func(dsp DsPlugin, seconds int) {
+	return makeTicker(time.Duration(seconds)*time.Second, func() { RequestUpdate(dsp) })
+}
+
+// makeTicker calls fn every interval in a goroutine, until the returned
+// channel is closed.
+func makeTicker(interval time.Duration, fn func()) chan interface{} {
+	closeToKill := make(chan interface{})
+	go func() {
 		for {
 			select {
-			case <-closeToKillUpdate:
+			case <-closeToKill:
 				return
-			case <-time.After(time.Duration(seconds) * time.Second):
-				dsp.UpdateNow()
+			case <-time.After(interval):
+				fn()
 			}
 		}
-	}(dsp, seconds)
-	return closeToKillUpdate
+	}()
+	return closeToKill
 }
 
 // DsPluginDefinition is a Datasource Plugin
@@ -89,15 +117,50 @@ type DsPluginDefinition struct {
 	// the definition's settings array, as a js.Object; this should
 	// be retained. It is also passed one special function, which
 	// is a simple go wrapper around the updateCallback function
-	// given by the FreeBoard NewInstance function.
+	// given by the FreeBoard NewInstance function. An error passed
+	// to updateCallback is counted in the instance's DsMetrics, and
+	// sent on to freeboard like any other value.
 	// Notably absent is NewInstanceCallback; this is handled under
 	// the Go layer for you. All you have to do is return the
 	// prepared DsPlugin-interfacing plugin object.
 	NewInstance func(settings *js.Object, updateCallback func(interface{})) DsPlugin
+
+	// NewHostedInstance may be given instead of NewInstance, for plugins
+	// that need the Host they're loaded into, e.g. to look up other
+	// datasources' settings. Taking the Host as a dependency, rather than
+	// using FB, lets them be tested with a fake host.
+	NewHostedInstance func(host Host, settings *js.Object, updateCallback func(interface{})) DsPlugin
+
+	// CacheLastValue turns on the last-value cache: the last payload of
+	// each instance is kept in localStorage, keyed by the datasource's
+	// name, and replayed on reload before the first live update so that
+	// widgets aren't left blank. Replayed object payloads are marked
+	// with CachedFlag, and every replayed payload fires
+	// EventDatasourceUpdated with EventData.Cached set.
+	//
+	// Freeboard doesn't tell plugins their datasource's name, so it's
+	// found by matching the instance's settings against the board. An
+	// instance with the same settings as another datasource of its type,
+	// as on a board with a copied datasource, can't be told apart, and
+	// isn't cached.
+	CacheLastValue bool
+
+	// UpdatePolicy controls what happens when UpdateNow is called while
+	// an earlier call is still running. The default, UpdateConcurrent,
+	// lets calls overlap.
+	UpdatePolicy UpdatePolicy
 }
 
 // ToFBInterface returns a map for FreeBoard's loadDatasourcePlugin func.
+// Plugins are given DefaultHost; FBWrapper.AddDatasourcePlugin uses
+// ToFBInterfaceFor instead, to give them the FBWrapper.
 func (dsp DsPluginDefinition) ToFBInterface() map[string]interface{} {
+	return dsp.ToFBInterfaceFor(nil)
+}
+
+// ToFBInterfaceFor is ToFBInterface for plugins loaded into host. A nil
+// host means DefaultHost, as it is when each instance is made.
+func (dsp DsPluginDefinition) ToFBInterfaceFor(host Host) map[string]interface{} {
 	output := make(map[string]interface{})
 	output["type_name"] = dsp.TypeName
 	output["display_name"] = dsp.DisplayName
@@ -112,9 +175,49 @@ func (dsp DsPluginDefinition) ToFBInterface() map[string]interface{} {
 	}
 	output["settings"] = settingSlice
 	output["newInstance"] = func(settings, newInstanceCallback, updateCallback *js.Object) {
-		Plugin := dsp.NewInstance(settings, func(i interface{}) { updateCallback.Invoke(i) })
-		wrapper := WrapDsPlugin(Plugin)
+		guard := newPluginGuard(dsp.TypeName)
+		defer guard.recover("newInstance")
+		host := host
+		if host == nil {
+			host = defaultHost()
+		}
+		update := func(i interface{}) { updateCallback.Invoke(i) }
+		var cache *valueCache
+		if dsp.CacheLastValue {
+			cache = newValueCache(host, dsp.TypeName, settings)
+			update = cache.wrapUpdate(update)
+		}
+		instrument := newDsInstrument(host, dsp.TypeName, settings)
+		update = instrument.wrapUpdate(update)
+		flight := newUpdateFlight(dsp.UpdatePolicy, guard)
+		flight.started = instrument.updateStarted
+		update = flight.wrapUpdate(update)
+		// A panic is counted against the instance, which is marked as
+		// errored in its metrics and in freeboard's datasource list, but
+		// its last payload is left alone, so widgets bound to it keep
+		// showing data.
+		instrument.guard = guard
+		guard.onPanic = append(guard.onPanic, instrument.recordPanic)
+		var Plugin DsPlugin
+		if dsp.NewHostedInstance != nil {
+			Plugin = dsp.NewHostedInstance(host, settings, update)
+		} else {
+			Plugin = dsp.NewInstance(settings, update)
+		}
+		wrapper := wrapDsPlugin(Plugin, flight, guard)
+		instrument.instrument(wrapper)
+		if cache != nil {
+			onSettingsChanged := wrapper["onSettingsChanged"].(func(*js.Object))
+			wrapper["onSettingsChanged"] = func(settings *js.Object) {
+				cache.settingsChanged(settings)
+				onSettingsChanged(settings)
+			}
+		}
 		newInstanceCallback.Invoke(wrapper)
+		instrument.emit(EventPluginCreated, nil, nil)
+		if cache != nil {
+			go cache.emitCached(updateCallback, instrument.recordReplay)
+		}
 	}
 	return output
 }
@@ -0,0 +1,217 @@
+package freeboard
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event names an event that can be subscribed to with Subscribe.
+type Event string
+
+// Events fired by freeboard.
+const (
+	EventDashboardLoaded Event = "dashboard_loaded"
+	EventInitialized     Event = "initialized"
+)
+
+// Events fired by this package. Datasource and plugin events are only
+// fired for plugins written in Go.
+const (
+	// EventDatasourceUpdated is fired for every payload a datasource sends
+	// freeboard; EventData.Value is the payload. It's also fired for a
+	// payload replayed from the last-value cache, with EventData.Cached
+	// set.
+	EventDatasourceUpdated Event = "gofreeboard_datasource_updated"
+	// EventDatasourceError is fired when a datasource reports an error or
+	// panics; EventData.Err is the error.
+	EventDatasourceError Event = "gofreeboard_datasource_error"
+	// EventPluginCreated and EventPluginDisposed are fired when freeboard
+	// makes or disposes of a plugin instance; EventData.Kind says whether
+	// it's a datasource or a widget.
+	EventPluginCreated  Event = "gofreeboard_plugin_created"
+	EventPluginDisposed Event = "gofreeboard_plugin_disposed"
+	// EventEditModeChanged is fired when the board enters or leaves edit
+	// mode; EventData.Editing is the new mode. Freeboard has no event for
+	// this, so the mode is checked every editModePollInterval while
+	// there are subscribers.
+	EventEditModeChanged Event = "gofreeboard_edit_mode_changed"
+)
+
+// Plugin kinds, for EventData.Kind.
+const (
+	PluginKindDatasource = "datasource"
+	PluginKindWidget     = "widget"
+)
+
+// editModePollInterval is how often the edit mode is checked.
+const editModePollInterval = 250 * time.Millisecond
+
+// EventData describes an event. Only the fields that suit the event are
+// set.
+type EventData struct {
+	Event Event
+	// Kind is PluginKindDatasource or PluginKindWidget.
+	Kind string
+	// TypeName is the plugin's TypeName.
+	TypeName string
+	// Name is the datasource's name, if it is known.
+	Name    string
+	Value   interface{}
+	Err     error
+	Editing bool
+	// Cached is set if Value was replayed from the last-value cache
+	// rather than sent by the datasource.
+	Cached bool
+}
+
+// Subscription is a handler subscribed with Subscribe.
+type Subscription struct {
+	event Event
+	id    int
+}
+
+// events holds the handlers of every subscription, by event and id.
+var events = struct {
+	sync.Mutex
+	handlers map[Event]map[int]func(EventData)
+	serial   int
+	// hooked records the freeboard events each wrapper has passed on.
+	hooked map[*FBWrapper]map[Event]bool
+	// closeToKillPoll stops each wrapper's edit mode poll.
+	closeToKillPoll map[*FBWrapper]chan interface{}
+}{
+	handlers:        make(map[Event]map[int]func(EventData)),
+	hooked:          make(map[*FBWrapper]map[Event]bool),
+	closeToKillPoll: make(map[*FBWrapper]chan interface{}),
+}
+
+// Subscribe calls fn whenever event happens, until the returned
+// Subscription is unsubscribed. Events fired by this package are passed
+// to every subscriber, whichever FBWrapper they subscribed through.
+// fn is called as the event happens, so it should return promptly.
+func (fb *FBWrapper) Subscribe(event Event, fn func(EventData)) *Subscription {
+	events.Lock()
+	defer events.Unlock()
+	sub := subscribe(event, fn)
+	switch event {
+	case EventDashboardLoaded, EventInitialized:
+		// Freeboard can't unsubscribe, so it's subscribed once, and
+		// passes its events on to the handlers registered at the time.
+		if events.hooked[fb] == nil {
+			events.hooked[fb] = make(map[Event]bool)
+		}
+		if !events.hooked[fb][event] {
+			events.hooked[fb][event] = true
+			_, err := fb.call("on", string(event), func() {
+				go emitEvent(EventData{Event: event})
+			})
+			if err != nil {
+				// Left unhooked, so that a later Subscribe tries again.
+				events.hooked[fb][event] = false
+				logError("freeboard: subscribing to", string(event)+":", err.Error())
+			}
+		}
+	case EventEditModeChanged:
+		if events.closeToKillPoll[fb] == nil {
+			events.closeToKillPoll[fb] = fb.pollEditMode()
+		}
+	}
+	return sub
+}
+
+// SubscribeEvent subscribes fn to an event without going through a Host,
+// so it's only called for events fired by this package or by FireEvent.
+// Hosts other than FBWrapper can use it to implement Subscribe.
+func SubscribeEvent(event Event, fn func(EventData)) *Subscription {
+	events.Lock()
+	defer events.Unlock()
+	return subscribe(event, fn)
+}
+
+// subscribe registers a handler. The caller must hold the lock.
+func subscribe(event Event, fn func(EventData)) *Subscription {
+	events.serial++
+	sub := &Subscription{event: event, id: events.serial}
+	if events.handlers[event] == nil {
+		events.handlers[event] = make(map[int]func(EventData))
+	}
+	events.handlers[event][sub.id] = fn
+	return sub
+}
+
+// FireEvent calls the handlers subscribed to data.Event, as if the event
+// had happened. Hosts other than FBWrapper use it to fire freeboard's
+// events.
+func FireEvent(data EventData) {
+	emitEvent(data)
+}
+
+// Unsubscribe stops the handler being called. It's safe to call more
+// than once.
+func (sub *Subscription) Unsubscribe() {
+	events.Lock()
+	defer events.Unlock()
+	delete(events.handlers[sub.event], sub.id)
+	if sub.event == EventEditModeChanged && len(events.handlers[sub.event]) == 0 {
+		for fb, closeToKill := range events.closeToKillPoll {
+			close(closeToKill)
+			delete(events.closeToKillPoll, fb)
+		}
+	}
+}
+
+// pollEditMode fires EventEditModeChanged when the edit mode changes.
+// Close the returned channel to stop.
+func (fb *FBWrapper) pollEditMode() chan interface{} {
+	var mu sync.Mutex
+	editing, _ := fb.EditMode()
+	return makeTicker(editModePollInterval, func() {
+		now, err := fb.EditMode()
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		changed := now != editing
+		editing = now
+		mu.Unlock()
+		if changed {
+			emitEvent(EventData{Event: EventEditModeChanged, Editing: now})
+		}
+	})
+}
+
+// emitEvent calls the handlers of an event, in the order they
+// subscribed. A handler that panics is logged, and doesn't stop the
+// others.
+func emitEvent(data EventData) {
+	events.Lock()
+	ids := make([]int, 0, len(events.handlers[data.Event]))
+	for id := range events.handlers[data.Event] {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	handlers := make([]func(EventData), len(ids))
+	for i, id := range ids {
+		handlers[i] = events.handlers[data.Event][id]
+	}
+	events.Unlock()
+	for _, fn := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logError("freeboard: panic in handler for "+string(data.Event)+":", panicMessage(r))
+				}
+			}()
+			fn(data)
+		}()
+	}
+}
+
+// hasSubscribers reports whether anyone is subscribed to event, so that
+// events that are costly to describe can be skipped.
+func hasSubscribers(event Event) bool {
+	events.Lock()
+	defer events.Unlock()
+	return len(events.handlers[event]) > 0
+}
@@ -0,0 +1,133 @@
+package freeboard
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// siPrefixes are the SI prefixes used by FormatNumber, largest first.
+var siPrefixes = []struct {
+	symbol string
+	scale  float64
+}{
+	{"E", 1e18}, {"P", 1e15}, {"T", 1e12}, {"G", 1e9}, {"M", 1e6}, {"k", 1e3},
+	{"", 1},
+	{"m", 1e-3}, {"µ", 1e-6}, {"n", 1e-9}, {"p", 1e-12},
+}
+
+// SIScale returns v scaled into [1, 1000) and the matching SI prefix,
+// e.g. 12345 gives 12.345 and "k". Zero, infinities and NaN are returned
+// unchanged, with no prefix.
+func SIScale(v float64) (float64, string) {
+	abs := math.Abs(v)
+	if abs == 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return v, ""
+	}
+	for _, p := range siPrefixes {
+		if abs >= p.scale {
+			return v / p.scale, p.symbol
+		}
+	}
+	last := siPrefixes[len(siPrefixes)-1]
+	return v / last.scale, last.symbol
+}
+
+// FormatNumber formats v with the given number of decimal places, using
+// the browser's locale for digit grouping and the decimal mark. If si is
+// true, v is first scaled by SIScale and the prefix is appended. A negative
+// decimals leaves the number of decimal places up to the locale.
+func FormatNumber(v float64, decimals int, si bool) string {
+	prefix := ""
+	if si {
+		v, prefix = SIScale(v)
+	}
+	return formatLocale(v, decimals) + prefix
+}
+
+// formatLocale formats v in the browser's locale, falling back to
+// strconv outside a browser.
+func formatLocale(v float64, decimals int) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = strconv.FormatFloat(v, 'f', decimals, 64)
+		}
+	}()
+	opts := js.Global.Get("Object").New()
+	if decimals >= 0 {
+		opts.Set("minimumFractionDigits", decimals)
+		opts.Set("maximumFractionDigits", decimals)
+	}
+	return js.Global.Get("Number").New(v).Call("toLocaleString", js.Undefined, opts).String()
+}
+
+// toFloat converts a calculated value to a number if it is one, or is
+// a string holding one.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// settingString reads a setting as a string, or "" if it is unset.
+func settingString(settings *js.Object, name string) string {
+	v := settings.Get(name)
+	if v == js.Undefined || v == nil {
+		return ""
+	}
+	return v.String()
+}
+
+// settingFloat reads a numeric setting, or def if it is unset or not a number.
+func settingFloat(settings *js.Object, name string, def float64) float64 {
+	v := settings.Get(name)
+	if v == js.Undefined || v == nil {
+		return def
+	}
+	if f, ok := toFloat(v.Interface()); ok {
+		return f
+	}
+	return def
+}
+
+// settingBool reads a boolean setting, or false if it is unset.
+func settingBool(settings *js.Object, name string) bool {
+	v := settings.Get(name)
+	if v == js.Undefined || v == nil {
+		return false
+	}
+	return v.Bool()
+}
+
+// settingRows reads an array-type setting as its rows, each an object
+// keyed by the names of the setting's FBSettingSets.
+func settingRows(settings *js.Object, name string) []*js.Object {
+	v := settings.Get(name)
+	if v == js.Undefined || v == nil || !js.Global.Get("Array").Call("isArray", v).Bool() {
+		return nil
+	}
+	rows := make([]*js.Object, 0, v.Length())
+	for i := 0; i < v.Length(); i++ {
+		if row := v.Index(i); row != js.Undefined && row != nil {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// displayValue formats a non-numeric calculated value for display.
+func displayValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
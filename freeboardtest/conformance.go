@@ -0,0 +1,509 @@
+package freeboardtest
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cathalgarvey/go-freeboard"
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// ConformanceWait is how long the conformance checks give a plugin to
+// settle: for payloads to arrive after UpdateNow, and for late payloads
+// and goroutines to show themselves after disposal.
+var ConformanceWait = time.Second
+
+// RunDatasourceConformance checks that a datasource plugin behaves as
+// freeboard needs it to:
+//
+//   - its definition compiles to a valid plugin for freeboard
+//   - it can be made with its default settings, and updated
+//   - it survives its settings being changed
+//   - it sends no payloads after OnDispose, and the goroutines it
+//     started have stopped by then
+//   - missing, null and wrongly typed settings don't make it panic
+//
+// A panic in any of its methods fails the check. Errors passed to
+// updateCallback don't, as that's how plugins should report bad
+// settings. Call it from a test run with "gopherjs test":
+//
+//	func TestConformance(t *testing.T) {
+//		freeboardtest.RunDatasourceConformance(t, TestDefinition)
+//	}
+func RunDatasourceConformance(t *testing.T, def freeboard.DsPluginDefinition) {
+	t.Run("Interface", func(t *testing.T) {
+		checkInterface(t, def.TypeName, def.DisplayName, def.Settings, func(h *Host) { h.AddDatasourcePlugin(def) })
+		if def.NewInstance == nil && def.NewHostedInstance == nil {
+			t.Error("neither NewInstance nor NewHostedInstance is set")
+		}
+	})
+	newDatasource := func(t *testing.T, settings map[string]interface{}) (*Datasource, *panicLog) {
+		h := NewHost()
+		if err := callJS(func() { h.AddDatasourcePlugin(def) }); err != nil {
+			t.Fatal("compiling the plugin:", err)
+		}
+		panics := watchDatasourcePanics(def.TypeName)
+		ds, err := h.NewDatasource("conformance", def.TypeName, settings)
+		if err != nil {
+			panics.check(t)
+			t.Fatal("making an instance:", err)
+		}
+		return ds, panics
+	}
+	t.Run("DefaultSettings", func(t *testing.T) {
+		ds, panics := newDatasource(t, nil)
+		for _, method := range []string{"updateNow", "onDispose", "onSettingsChanged"} {
+			if isUndefined(ds.Instance().Get(method)) {
+				t.Error("the instance has no", method)
+			}
+		}
+		checkCall(t, "UpdateNow", ds.UpdateNow())
+		time.Sleep(ConformanceWait)
+		checkCall(t, "Dispose", ds.Dispose())
+		panics.check(t)
+	})
+	t.Run("SettingsChanges", func(t *testing.T) {
+		ds, panics := newDatasource(t, nil)
+		for _, settings := range changedSettings(def.Settings) {
+			checkCall(t, "SetSettings", ds.SetSettings(settings))
+			checkCall(t, "UpdateNow", ds.UpdateNow())
+		}
+		time.Sleep(ConformanceWait)
+		checkCall(t, "Dispose", ds.Dispose())
+		panics.check(t)
+	})
+	t.Run("Dispose", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		ds, panics := newDatasource(t, nil)
+		checkCall(t, "UpdateNow", ds.UpdateNow())
+		checkCall(t, "Dispose", ds.Dispose())
+		checkGoroutines(t, before)
+		time.Sleep(ConformanceWait)
+		if late := ds.UpdatesAfterDispose(); len(late) > 0 {
+			t.Errorf("%d payloads were sent after OnDispose, the first: %s", len(late), describe(late[0]))
+		}
+		panics.check(t)
+	})
+	for _, g := range garbageSettings(def.Settings) {
+		g := g
+		t.Run("GarbageSettings/"+g.name, func(t *testing.T) {
+			ds, panics := newDatasource(t, g.settings)
+			checkCall(t, "UpdateNow", ds.UpdateNow())
+			checkCall(t, "SetSettings", ds.SetSettings(g.settings))
+			checkCall(t, "UpdateNow", ds.UpdateNow())
+			time.Sleep(ConformanceWait / 4)
+			checkCall(t, "Dispose", ds.Dispose())
+			panics.check(t)
+		})
+	}
+}
+
+// RunWidgetConformance checks that a widget plugin behaves as freeboard
+// needs it to:
+//
+//   - its definition compiles to a valid plugin for freeboard
+//   - it can be made with its default settings, and rendered, if there's
+//     a DOM
+//   - it survives its settings being changed
+//   - it takes calculated values of any type
+//   - the goroutines it started have stopped once it's disposed of
+//   - missing, null and wrongly typed settings don't make it panic
+//
+// A panic in any of its methods fails the check. Call it from a test run
+// with "gopherjs test"; to check rendering, too, give it a DOM, such as
+// jsdom's.
+func RunWidgetConformance(t *testing.T, def freeboard.WtPluginDefinition) {
+	t.Run("Interface", func(t *testing.T) {
+		checkInterface(t, def.TypeName, def.DisplayName, def.Settings, func(h *Host) { h.AddWidgetPlugin(def) })
+		if def.NewInstance == nil && def.NewHostedInstance == nil {
+			t.Error("neither NewInstance nor NewHostedInstance is set")
+		}
+	})
+	newWidget := func(t *testing.T, settings map[string]interface{}) (*Widget, *panicLog) {
+		h := NewHost()
+		watched, panics := watchWidgetPanics(def)
+		if err := callJS(func() { h.AddWidgetPlugin(watched) }); err != nil {
+			t.Fatal("compiling the plugin:", err)
+		}
+		w, err := h.NewWidget(def.TypeName, settings)
+		if err != nil {
+			t.Fatal("making an instance:", err)
+		}
+		return w, panics
+	}
+	// exercise renders the widget, if it can, and asks its height, as
+	// freeboard does when it's put on the board.
+	exercise := func(t *testing.T, w *Widget) {
+		if !isUndefined(js.Global.Get("document")) {
+			_, err := w.Render()
+			checkCall(t, "Render", err)
+		}
+		_, err := w.Height()
+		checkCall(t, "Height", err)
+	}
+	t.Run("DefaultSettings", func(t *testing.T) {
+		w, panics := newWidget(t, nil)
+		for _, method := range []string{"render", "getHeight", "onDispose", "onSettingsChanged", "onCalculatedValueChanged"} {
+			if isUndefined(w.Instance().Get(method)) {
+				t.Error("the instance has no", method)
+			}
+		}
+		exercise(t, w)
+		checkCall(t, "Resize", w.Resize())
+		checkCall(t, "Dispose", w.Dispose())
+		panics.check(t)
+	})
+	t.Run("SettingsChanges", func(t *testing.T) {
+		w, panics := newWidget(t, nil)
+		exercise(t, w)
+		for _, settings := range changedSettings(def.Settings) {
+			checkCall(t, "SetSettings", w.SetSettings(settings))
+			exercise(t, w)
+		}
+		checkCall(t, "Dispose", w.Dispose())
+		panics.check(t)
+	})
+	t.Run("CalculatedValues", func(t *testing.T) {
+		w, panics := newWidget(t, nil)
+		exercise(t, w)
+		for _, s := range def.Settings {
+			if s.Type != freeboard.SettingCalculatedType {
+				continue
+			}
+			for _, v := range garbageValues() {
+				checkCall(t, "SetValue", w.SetValue(s.Name, v))
+			}
+		}
+		exercise(t, w)
+		checkCall(t, "Dispose", w.Dispose())
+		panics.check(t)
+	})
+	t.Run("Dispose", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		w, panics := newWidget(t, nil)
+		exercise(t, w)
+		checkCall(t, "Dispose", w.Dispose())
+		checkGoroutines(t, before)
+		panics.check(t)
+	})
+	for _, g := range garbageSettings(def.Settings) {
+		g := g
+		t.Run("GarbageSettings/"+g.name, func(t *testing.T) {
+			w, panics := newWidget(t, g.settings)
+			exercise(t, w)
+			checkCall(t, "SetSettings", w.SetSettings(g.settings))
+			exercise(t, w)
+			checkCall(t, "Dispose", w.Dispose())
+			panics.check(t)
+		})
+	}
+}
+
+// jsNamePattern matches valid JS names, as setting names must be.
+var jsNamePattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// checkInterface checks a plugin's definition, and that load, which
+// compiles it and loads it into a Host, doesn't panic.
+func checkInterface(t *testing.T, typeName, displayName string, settings []freeboard.FBSetting, load func(h *Host)) {
+	if typeName == "" {
+		t.Error("TypeName is empty")
+	}
+	if displayName == "" {
+		t.Error("DisplayName is empty")
+	}
+	names := make(map[string]bool)
+	for _, s := range settings {
+		if !jsNamePattern.MatchString(s.Name) {
+			t.Errorf("setting name %q isn't a valid JS name", s.Name)
+		}
+		if names[s.Name] {
+			t.Errorf("there's more than one setting %q", s.Name)
+		}
+		names[s.Name] = true
+		switch s.Type {
+		case freeboard.SettingTextType, freeboard.SettingNumberType, freeboard.SettingCalculatedType, freeboard.SettingBooleanType:
+		case freeboard.SettingOptionType:
+			if len(s.Options) == 0 {
+				t.Errorf("option setting %q has no options", s.Name)
+			}
+		case freeboard.SettingArrayType:
+			if len(s.Settings) == 0 {
+				t.Errorf("array setting %q has no settings", s.Name)
+			}
+		default:
+			t.Errorf("setting %q has unknown type %q", s.Name, s.Type)
+		}
+		if s.DefaultIntValue != 0 && s.DefaultFloatValue != 0 {
+			t.Errorf("setting %q has both DefaultIntValue and DefaultFloatValue", s.Name)
+		}
+	}
+	h := NewHost()
+	if err := callJS(func() { load(h) }); err != nil {
+		t.Fatal("compiling the plugin:", err)
+	}
+}
+
+// checkCall fails the test if a call to the instance failed.
+func checkCall(t *testing.T, call string, err error) {
+	if err != nil {
+		t.Errorf("%s: %s", call, err)
+	}
+}
+
+// checkGoroutines fails the test if more goroutines are running than
+// before, once they've had ConformanceWait to stop.
+func checkGoroutines(t *testing.T, before int) {
+	deadline := time.Now().Add(ConformanceWait)
+	n := runtime.NumGoroutine()
+	for n > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		n = runtime.NumGoroutine()
+	}
+	if n > before {
+		t.Errorf("%d goroutines are still running after OnDispose", n-before)
+	}
+}
+
+// describe returns a payload as JSON, for messages.
+func describe(payload *js.Object) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = "(unprintable)"
+		}
+	}()
+	return js.Global.Get("JSON").Call("stringify", payload).String()
+}
+
+// sampleValue returns a valid value for a setting, other than its
+// default where it can.
+func sampleValue(s freeboard.FBSetting) interface{} {
+	switch s.Type {
+	case freeboard.SettingNumberType:
+		return float64(s.DefaultIntValue) + s.DefaultFloatValue + 1
+	case freeboard.SettingBooleanType:
+		return true
+	case freeboard.SettingOptionType:
+		if len(s.Options) == 0 {
+			return ""
+		}
+		opt := s.Options[len(s.Options)-1]
+		if opt.Value != "" {
+			return opt.Value
+		}
+		return opt.Name
+	case freeboard.SettingArrayType:
+		row := make(map[string]interface{})
+		for _, sub := range s.Settings {
+			row[sub.Name] = sampleValue(freeboard.FBSetting{Name: sub.Name, Type: sub.Type})
+		}
+		return []interface{}{row}
+	case freeboard.SettingCalculatedType:
+		return "1"
+	}
+	return "conformance"
+}
+
+// defaultValue returns a setting's default value, as compiled for
+// freeboard, or nil if it has none.
+func defaultValue(s freeboard.FBSetting) (v interface{}) {
+	defer func() {
+		if recover() != nil {
+			v = nil
+		}
+	}()
+	return s.ToFBInterface()["default_value"]
+}
+
+// changedSettings returns a series of valid settings for a plugin:
+// first its defaults, then with each setting changed in turn.
+func changedSettings(settings []freeboard.FBSetting) []map[string]interface{} {
+	defaults := make(map[string]interface{})
+	for _, s := range settings {
+		if v := defaultValue(s); v != nil {
+			defaults[s.Name] = v
+		}
+	}
+	series := []map[string]interface{}{defaults}
+	for _, s := range settings {
+		changed := make(map[string]interface{})
+		for k, v := range defaults {
+			changed[k] = v
+		}
+		changed[s.Name] = sampleValue(s)
+		series = append(series, changed)
+	}
+	return series
+}
+
+// garbageValues are values of every JSON type, for settings and
+// calculated values that expect something else.
+func garbageValues() []interface{} {
+	return []interface{}{nil, "garbage", -1e300, true, []interface{}{}, map[string]interface{}{}}
+}
+
+// garbageSetting is a named set of bad settings.
+type garbageSetting struct {
+	name     string
+	settings map[string]interface{}
+}
+
+// garbageSettings returns bad settings for a plugin: none, all null, and
+// each of its settings given a value of the wrong type. New instances
+// are given defaults for the settings left out, as in freeboard, so
+// settings that are missing altogether only reach a plugin through
+// OnSettingsChanged.
+func garbageSettings(settings []freeboard.FBSetting) []garbageSetting {
+	nulls := make(map[string]interface{})
+	for _, s := range settings {
+		nulls[s.Name] = nil
+	}
+	out := []garbageSetting{{"Missing", map[string]interface{}{}}, {"Null", nulls}}
+	for _, s := range settings {
+		var wrong []interface{}
+		switch s.Type {
+		case freeboard.SettingNumberType:
+			wrong = []interface{}{"not a number", -1e300, map[string]interface{}{}}
+		case freeboard.SettingBooleanType:
+			wrong = []interface{}{"yes", 1.0}
+		case freeboard.SettingOptionType:
+			wrong = []interface{}{"no such option", 1.0}
+		case freeboard.SettingArrayType:
+			wrong = []interface{}{"not a list", []interface{}{"not a row", nil}, map[string]interface{}{}}
+		default:
+			wrong = []interface{}{12345.0, "", []interface{}{}, map[string]interface{}{}}
+		}
+		for i, v := range wrong {
+			bad := make(map[string]interface{})
+			for _, other := range settings {
+				if v := defaultValue(other); v != nil {
+					bad[other.Name] = v
+				}
+			}
+			bad[s.Name] = v
+			out = append(out, garbageSetting{fmt.Sprintf("%s/%d", s.Name, i), bad})
+		}
+	}
+	return out
+}
+
+// panicLog records the panics of a plugin's methods, which the freeboard
+// package recovers before freeboard, or Host, can see them.
+type panicLog struct {
+	sync.Mutex
+	panics []string
+	sub    *freeboard.Subscription
+}
+
+// watchDatasourcePanics records the panics of datasources of a type,
+// which the freeboard package reports as PanicErrors, until check is
+// called.
+func watchDatasourcePanics(typeName string) *panicLog {
+	pl := new(panicLog)
+	pl.sub = freeboard.SubscribeEvent(freeboard.EventDatasourceError, func(e freeboard.EventData) {
+		if pe, ok := e.Err.(*freeboard.PanicError); ok && e.TypeName == typeName {
+			pl.Lock()
+			pl.panics = append(pl.panics, pe.Callback+": "+pe.Message)
+			pl.Unlock()
+		}
+	})
+	return pl
+}
+
+// record must be deferred directly. It records a panic in the named
+// method, if there is one, and panics again.
+func (pl *panicLog) record(method string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	pl.Lock()
+	pl.panics = append(pl.panics, method+": "+fmt.Sprint(r))
+	pl.Unlock()
+	panic(r)
+}
+
+// check fails the test for each panic recorded, and stops recording
+// datasources' panics.
+func (pl *panicLog) check(t *testing.T) {
+	if pl.sub != nil {
+		pl.sub.Unsubscribe()
+	}
+	pl.Lock()
+	defer pl.Unlock()
+	for _, p := range pl.panics {
+		t.Error("panic in", p)
+	}
+}
+
+// watchWidgetPanics returns def with its instances wrapped to record
+// their panics.
+func watchWidgetPanics(def freeboard.WtPluginDefinition) (freeboard.WtPluginDefinition, *panicLog) {
+	pl := new(panicLog)
+	watch := func(wt freeboard.WidgetPlugin) freeboard.WidgetPlugin {
+		w := &watchedWidget{wt: wt, log: pl}
+		if rw, ok := wt.(freeboard.ResizableWidget); ok {
+			return &watchedResizableWidget{w, rw}
+		}
+		return w
+	}
+	if newInstance := def.NewInstance; newInstance != nil {
+		def.NewInstance = func(settings *js.Object) freeboard.WidgetPlugin {
+			defer pl.record("NewInstance")
+			return watch(newInstance(settings))
+		}
+	}
+	if newInstance := def.NewHostedInstance; newInstance != nil {
+		def.NewHostedInstance = func(host freeboard.Host, settings *js.Object) freeboard.WidgetPlugin {
+			defer pl.record("NewHostedInstance")
+			return watch(newInstance(host, settings))
+		}
+	}
+	return def, pl
+}
+
+// watchedWidget is a WidgetPlugin that records the panics of another.
+type watchedWidget struct {
+	wt  freeboard.WidgetPlugin
+	log *panicLog
+}
+
+func (w *watchedWidget) OnSettingsChanged(settings *js.Object) {
+	defer w.log.record("OnSettingsChanged")
+	w.wt.OnSettingsChanged(settings)
+}
+
+func (w *watchedWidget) OnCalculatedValueChanged(settingName string, newValue interface{}) {
+	defer w.log.record("OnCalculatedValueChanged")
+	w.wt.OnCalculatedValueChanged(settingName, newValue)
+}
+
+func (w *watchedWidget) Render(containerElement dom.HTMLElement) {
+	defer w.log.record("Render")
+	w.wt.Render(containerElement)
+}
+
+func (w *watchedWidget) GetHeight() int {
+	defer w.log.record("GetHeight")
+	return w.wt.GetHeight()
+}
+
+func (w *watchedWidget) OnDispose() {
+	defer w.log.record("OnDispose")
+	w.wt.OnDispose()
+}
+
+// watchedResizableWidget is a watchedWidget for a ResizableWidget.
+type watchedResizableWidget struct {
+	*watchedWidget
+	rw freeboard.ResizableWidget
+}
+
+func (w *watchedResizableWidget) OnSizeChanged() {
+	defer w.log.record("OnSizeChanged")
+	w.rw.OnSizeChanged()
+}
@@ -0,0 +1,31 @@
+//go:build js
+// +build js
+
+package freeboardtest
+
+import (
+	"testing"
+
+	"github.com/cathalgarvey/go-freeboard"
+)
+
+func TestBuiltinDatasourceConformance(t *testing.T) {
+	for _, def := range []freeboard.DsPluginDefinition{freeboard.MetricsDatasource} {
+		def := def
+		t.Run(def.TypeName, func(t *testing.T) { RunDatasourceConformance(t, def) })
+	}
+}
+
+func TestBuiltinWidgetConformance(t *testing.T) {
+	for _, def := range []freeboard.WtPluginDefinition{
+		freeboard.TextWidget,
+		freeboard.GaugeWidget,
+		freeboard.ChartWidget,
+		freeboard.TableWidget,
+		freeboard.IndicatorWidget,
+		freeboard.TemplateWidget,
+	} {
+		def := def
+		t.Run(def.TypeName, func(t *testing.T) { RunWidgetConformance(t, def) })
+	}
+}
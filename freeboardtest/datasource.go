@@ -0,0 +1,232 @@
+package freeboardtest
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Datasource is an instance of a datasource plugin on a Host, made with
+// Host.NewDatasource. It records every payload the instance sends to
+// its updateCallback.
+type Datasource struct {
+	Name     string
+	TypeName string
+
+	host     *Host
+	mu       sync.Mutex
+	settings *js.Object
+	instance *js.Object
+	payloads []*js.Object
+	disposed bool
+	// late are the payloads sent after OnDispose.
+	late     []*js.Object
+	watchers []func(*js.Object)
+}
+
+// newDatasource calls the plugin's newInstance, as freeboard does. The
+// datasource is on the board while the instance is made, as it is in
+// freeboard, so that the plugin can find its name.
+func newDatasource(h *Host, name, typeName string, plugin, settings *js.Object) (*Datasource, error) {
+	ds := &Datasource{Name: name, TypeName: typeName, host: h, settings: settings}
+	h.Lock()
+	h.datasources = append(h.datasources, ds)
+	h.Unlock()
+	newInstanceCallback := js.MakeFunc(func(this *js.Object, args []*js.Object) interface{} {
+		if len(args) > 0 {
+			ds.mu.Lock()
+			ds.instance = args[0]
+			ds.mu.Unlock()
+		}
+		return nil
+	})
+	updateCallback := js.MakeFunc(func(this *js.Object, args []*js.Object) interface{} {
+		payload := js.Undefined
+		if len(args) > 0 {
+			payload = args[0]
+		}
+		ds.update(payload)
+		return nil
+	})
+	err := callJS(func() { plugin.Call("newInstance", settings, newInstanceCallback, updateCallback) })
+	if err == nil && ds.Instance() == nil {
+		err = errors.New("freeboardtest: " + strconv.Quote(typeName) + " didn't make an instance")
+	}
+	if err != nil {
+		h.remove(ds, nil)
+		return nil, err
+	}
+	return ds, nil
+}
+
+// callJS calls fn, returning a panic, such as a thrown JS exception, as
+// an error.
+func callJS(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case error:
+				err = r
+			case string:
+				err = errors.New(r)
+			default:
+				err = errors.New("freeboardtest: panic in plugin")
+			}
+		}
+	}()
+	fn()
+	return nil
+}
+
+// update records a payload sent to updateCallback.
+func (ds *Datasource) update(payload *js.Object) {
+	ds.mu.Lock()
+	if ds.disposed {
+		ds.late = append(ds.late, payload)
+		ds.mu.Unlock()
+		return
+	}
+	ds.payloads = append(ds.payloads, payload)
+	watchers := append([]func(*js.Object){}, ds.watchers...)
+	ds.mu.Unlock()
+	for _, fn := range watchers {
+		fn(payload)
+	}
+}
+
+// watch calls fn with each later payload.
+func (ds *Datasource) watch(fn func(*js.Object)) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.watchers = append(ds.watchers, fn)
+}
+
+// Instance returns the object the plugin gave freeboard: for a Go
+// plugin, its wrapped DsPlugin, with "updateNow", "onDispose" and so on.
+func (ds *Datasource) Instance() *js.Object {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.instance
+}
+
+// Settings returns the instance's settings, the same object that was
+// given to it.
+func (ds *Datasource) Settings() *js.Object {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.settings
+}
+
+// SetSettings replaces the instance's settings and calls its
+// onSettingsChanged, as freeboard does when the settings dialog is
+// saved. Unlike NewDatasource, defaults aren't filled in.
+func (ds *Datasource) SetSettings(settings map[string]interface{}) error {
+	o, err := jsonToJS(settings)
+	if err != nil {
+		return err
+	}
+	return ds.setSettings(o)
+}
+
+func (ds *Datasource) setSettings(settings *js.Object) error {
+	ds.mu.Lock()
+	ds.settings = settings
+	ds.mu.Unlock()
+	return ds.call("onSettingsChanged", settings)
+}
+
+// UpdateNow calls the instance's updateNow, as freeboard does when the
+// datasource's refresh button is pressed. For a Go plugin, payloads may
+// arrive after it returns; see WaitForPayloads.
+func (ds *Datasource) UpdateNow() error {
+	return ds.call("updateNow")
+}
+
+// Dispose calls the instance's onDispose and takes it off the board, as
+// freeboard does when the datasource is deleted. Payloads sent after
+// that are kept apart; see UpdatesAfterDispose.
+func (ds *Datasource) Dispose() error {
+	ds.mu.Lock()
+	if ds.disposed {
+		ds.mu.Unlock()
+		return nil
+	}
+	ds.mu.Unlock()
+	err := ds.call("onDispose")
+	ds.mu.Lock()
+	ds.disposed = true
+	ds.mu.Unlock()
+	ds.host.remove(ds, nil)
+	return err
+}
+
+// Disposed reports whether Dispose has been called.
+func (ds *Datasource) Disposed() bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.disposed
+}
+
+// call calls one of the instance's methods.
+func (ds *Datasource) call(method string, args ...interface{}) error {
+	instance := ds.Instance()
+	if isUndefined(instance.Get(method)) {
+		return errors.New("freeboardtest: " + strconv.Quote(ds.TypeName) + " has no " + method)
+	}
+	return callJS(func() { instance.Call(method, args...) })
+}
+
+// Payloads returns the payloads sent to updateCallback so far, oldest
+// first.
+func (ds *Datasource) Payloads() []*js.Object {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return append([]*js.Object(nil), ds.payloads...)
+}
+
+// LastPayload returns the latest payload, or nil if there's none yet.
+func (ds *Datasource) LastPayload() *js.Object {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if len(ds.payloads) == 0 {
+		return nil
+	}
+	return ds.payloads[len(ds.payloads)-1]
+}
+
+// DecodePayload unmarshals payload i into v, by way of JSON.
+func (ds *Datasource) DecodePayload(i int, v interface{}) error {
+	payloads := ds.Payloads()
+	if i < 0 || i >= len(payloads) {
+		return errors.New("freeboardtest: no payload " + strconv.Itoa(i))
+	}
+	return jsToGo(payloads[i], v)
+}
+
+// WaitForPayloads waits until at least n payloads have been sent, and
+// returns them. If they haven't within timeout, it returns those there
+// are, with an error. Call it from a test's goroutine, not a callback.
+func (ds *Datasource) WaitForPayloads(n int, timeout time.Duration) ([]*js.Object, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		payloads := ds.Payloads()
+		if len(payloads) >= n {
+			return payloads, nil
+		}
+		if time.Now().After(deadline) {
+			return payloads, errors.New("freeboardtest: " + strconv.Itoa(len(payloads)) + " of " + strconv.Itoa(n) + " payloads from " + strconv.Quote(ds.Name) + " within " + timeout.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// UpdatesAfterDispose returns the payloads sent after Dispose, which a
+// well-behaved plugin never sends.
+func (ds *Datasource) UpdatesAfterDispose() []*js.Object {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return append([]*js.Object(nil), ds.late...)
+}
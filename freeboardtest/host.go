@@ -0,0 +1,450 @@
+// Package freeboardtest is a fake freeboard for testing plugins without
+// a browser. It only works under GopherJS: plugins are driven through
+// JS, as freeboard drives them, so tests must run with "gopherjs test"
+// on Node, and Widget.Render also needs a DOM, such as jsdom's. Under
+// plain "go test" there's no JS, and the package panics, so give test
+// files that use it a js build constraint.
+//
+// Host takes plugins as freeboard does, from the maps made by
+// DsPluginDefinition.ToFBInterface and WtPluginDefinition.ToFBInterface,
+// and lets tests make instances, change their settings, update and
+// dispose of them, and see everything they do, as testplugin's TestCats
+// does:
+//
+//	func TestCats(t *testing.T) {
+//		host := freeboardtest.NewHost()
+//		host.AddDatasourcePlugin(TestDefinition)
+//		ds, err := host.NewDatasource("cats", "catsplugin", nil)
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		ds.UpdateNow()
+//		payloads, err := ds.WaitForPayloads(1, time.Second)
+//		...
+//	}
+package freeboardtest
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/cathalgarvey/go-freeboard"
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// Host is an in-memory freeboard.Host. The zero value is not usable;
+// use NewHost.
+type Host struct {
+	sync.Mutex
+	dsPlugins   map[string]*js.Object
+	wtPlugins   map[string]*js.Object
+	datasources []*Datasource
+	widgets     []*Widget
+	// panes are the panes of the loaded board, which widgets are in.
+	panes     []freeboard.DashboardPane
+	columns   int
+	allowEdit bool
+	editing   bool
+	loading   bool
+	dialogs   []string
+}
+
+// NewHost returns a Host with no plugins and an empty board.
+func NewHost() *Host {
+	return &Host{
+		dsPlugins: make(map[string]*js.Object),
+		wtPlugins: make(map[string]*js.Object),
+		columns:   3,
+		allowEdit: true,
+	}
+}
+
+var _ freeboard.Host = (*Host)(nil)
+
+// toJS converts a Go value to JS the way freeboard receives it when a Go
+// plugin is loaded, with Go funcs becoming JS functions.
+func toJS(v interface{}) *js.Object {
+	return js.Global.Get("Object").Call("assign", js.Global.Get("Object").New(), v)
+}
+
+// jsonToJS converts a Go value to a plain JS object by way of JSON, as
+// settings and dashboards are.
+func jsonToJS(v interface{}) (*js.Object, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return js.Global.Get("JSON").Call("parse", string(data)), nil
+}
+
+// jsToGo converts a plain JS value to Go by way of JSON.
+func jsToGo(o *js.Object, v interface{}) error {
+	return json.Unmarshal([]byte(js.Global.Get("JSON").Call("stringify", o).String()), v)
+}
+
+// isUndefined reports whether o is JS null or undefined.
+func isUndefined(o *js.Object) bool {
+	return o == nil || o == js.Undefined
+}
+
+// LoadDatasourceMap loads a datasource plugin from the map made by
+// DsPluginDefinition.ToFBInterface.
+func (h *Host) LoadDatasourceMap(m map[string]interface{}) {
+	h.LoadDatasourcePlugin(toJS(m))
+}
+
+// LoadWidgetMap loads a widget plugin from the map made by
+// WtPluginDefinition.ToFBInterface.
+func (h *Host) LoadWidgetMap(m map[string]interface{}) {
+	h.LoadWidgetPlugin(toJS(m))
+}
+
+// LoadDatasourcePlugin loads a datasource plugin written in JS.
+func (h *Host) LoadDatasourcePlugin(ds *js.Object) {
+	h.Lock()
+	defer h.Unlock()
+	h.dsPlugins[ds.Get("type_name").String()] = ds
+}
+
+// AddDatasourcePlugin satisfies freeboard.Host. The plugin is given
+// this Host.
+func (h *Host) AddDatasourcePlugin(ds freeboard.DsPluginDefinition) error {
+	h.LoadDatasourceMap(ds.ToFBInterfaceFor(h))
+	return nil
+}
+
+// LoadWidgetPlugin loads a widget plugin written in JS.
+func (h *Host) LoadWidgetPlugin(wt *js.Object) {
+	h.Lock()
+	defer h.Unlock()
+	h.wtPlugins[wt.Get("type_name").String()] = wt
+}
+
+// AddWidgetPlugin satisfies freeboard.Host. The widget is given this
+// Host.
+func (h *Host) AddWidgetPlugin(wt freeboard.WtPluginDefinition) error {
+	h.LoadWidgetMap(wt.ToFBInterfaceFor(h))
+	return nil
+}
+
+// withDefaults returns settings as a JS object, with the default values
+// of the plugin's settings filled in, as freeboard's settings dialog
+// does for new instances.
+func withDefaults(plugin *js.Object, settings map[string]interface{}) (*js.Object, error) {
+	if settings == nil {
+		settings = make(map[string]interface{})
+	}
+	o, err := jsonToJS(settings)
+	if err != nil {
+		return nil, err
+	}
+	defs := plugin.Get("settings")
+	if isUndefined(defs) {
+		return o, nil
+	}
+	for i := 0; i < defs.Length(); i++ {
+		def := defs.Index(i)
+		name := def.Get("name").String()
+		if _, given := settings[name]; given || isUndefined(def.Get("default_value")) {
+			continue
+		}
+		o.Set(name, def.Get("default_value"))
+	}
+	return o, nil
+}
+
+// NewDatasource makes an instance of a datasource plugin, as freeboard
+// does when one is added to the board. Settings that aren't given take
+// their default values.
+func (h *Host) NewDatasource(name, typeName string, settings map[string]interface{}) (*Datasource, error) {
+	h.Lock()
+	plugin := h.dsPlugins[typeName]
+	exists := h.datasource(name) != nil
+	h.Unlock()
+	if plugin == nil {
+		return nil, errors.New("freeboardtest: no datasource plugin " + strconv.Quote(typeName))
+	}
+	if exists {
+		return nil, errors.New("freeboardtest: there is already a datasource " + strconv.Quote(name))
+	}
+	o, err := withDefaults(plugin, settings)
+	if err != nil {
+		return nil, err
+	}
+	return newDatasource(h, name, typeName, plugin, o)
+}
+
+// NewWidget makes an instance of a widget plugin, as freeboard does when
+// one is added to the board, in the last pane. It isn't rendered until
+// Render is called. Settings that aren't given take their default
+// values.
+func (h *Host) NewWidget(typeName string, settings map[string]interface{}) (*Widget, error) {
+	h.Lock()
+	plugin := h.wtPlugins[typeName]
+	if len(h.panes) == 0 {
+		h.panes = append(h.panes, freeboard.DashboardPane{Width: 1})
+	}
+	pane := len(h.panes) - 1
+	h.Unlock()
+	if plugin == nil {
+		return nil, errors.New("freeboardtest: no widget plugin " + strconv.Quote(typeName))
+	}
+	o, err := withDefaults(plugin, settings)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newWidget(h, typeName, plugin, o, pane)
+	if err != nil {
+		return nil, err
+	}
+	h.Lock()
+	h.widgets = append(h.widgets, w)
+	h.Unlock()
+	return w, nil
+}
+
+// Datasource returns the datasource instance with the given name, or nil.
+func (h *Host) Datasource(name string) *Datasource {
+	h.Lock()
+	defer h.Unlock()
+	return h.datasource(name)
+}
+
+// datasource is Datasource. The caller must hold the lock.
+func (h *Host) datasource(name string) *Datasource {
+	for _, ds := range h.datasources {
+		if ds.Name == name {
+			return ds
+		}
+	}
+	return nil
+}
+
+// remove takes a datasource or widget off the board.
+func (h *Host) remove(ds *Datasource, w *Widget) {
+	h.Lock()
+	defer h.Unlock()
+	for i, other := range h.datasources {
+		if other == ds {
+			h.datasources = append(h.datasources[:i], h.datasources[i+1:]...)
+			break
+		}
+	}
+	for i, other := range h.widgets {
+		if other == w {
+			h.widgets = append(h.widgets[:i], h.widgets[i+1:]...)
+			break
+		}
+	}
+}
+
+// Datasources returns the datasource instances on the board.
+func (h *Host) Datasources() []*Datasource {
+	h.Lock()
+	defer h.Unlock()
+	return append([]*Datasource(nil), h.datasources...)
+}
+
+// Widgets returns the widget instances on the board.
+func (h *Host) Widgets() []*Widget {
+	h.Lock()
+	defer h.Unlock()
+	return append([]*Widget(nil), h.widgets...)
+}
+
+// InitializeBoard satisfies freeboard.Host. It fires "initialized".
+func (h *Host) InitializeBoard(allowEdit bool, finished func()) error {
+	h.Lock()
+	h.allowEdit = allowEdit
+	h.Unlock()
+	freeboard.FireEvent(freeboard.EventData{Event: freeboard.EventInitialized})
+	if finished != nil {
+		finished()
+	}
+	return nil
+}
+
+// InitializeSync satisfies freeboard.Host.
+func (h *Host) InitializeSync(allowEdit bool) error {
+	return h.InitializeBoard(allowEdit, nil)
+}
+
+// ClearDashboard satisfies freeboard.Host, disposing of every instance.
+func (h *Host) ClearDashboard() error {
+	h.Lock()
+	datasources, widgets := h.datasources, h.widgets
+	h.datasources, h.widgets, h.panes = nil, nil, nil
+	h.Unlock()
+	for _, w := range widgets {
+		w.Dispose()
+	}
+	for _, ds := range datasources {
+		ds.Dispose()
+	}
+	return nil
+}
+
+// SerializeDashboard satisfies freeboard.Host, describing the instances
+// on the board.
+func (h *Host) SerializeDashboard() (*freeboard.Dashboard, error) {
+	d := freeboard.NewDashboard(h.columns)
+	h.Lock()
+	d.AllowEdit = h.allowEdit
+	datasources, widgets := h.datasources, h.widgets
+	d.Panes = make([]freeboard.DashboardPane, len(h.panes))
+	for i, pane := range h.panes {
+		pane.Widgets = []freeboard.DashboardWidget{}
+		d.Panes[i] = pane
+	}
+	h.Unlock()
+	for _, ds := range datasources {
+		settings := make(map[string]interface{})
+		if err := jsToGo(ds.Settings(), &settings); err != nil {
+			return nil, err
+		}
+		d.Datasources = append(d.Datasources, freeboard.DashboardDatasource{Name: ds.Name, Type: ds.TypeName, Settings: settings})
+	}
+	for _, w := range widgets {
+		settings := make(map[string]interface{})
+		if err := jsToGo(w.Settings(), &settings); err != nil {
+			return nil, err
+		}
+		pane := &d.Panes[w.pane]
+		pane.Widgets = append(pane.Widgets, freeboard.DashboardWidget{Type: w.TypeName, Settings: settings})
+	}
+	return d, nil
+}
+
+// LoadGoDashboard satisfies freeboard.Host. It replaces the board's
+// instances with the dashboard's, binding widgets' calculated settings
+// that read a datasource field, such as datasources["weather"]["temp"].
+// Other calculated settings aren't evaluated; use Widget.SetValue. It
+// fires "dashboard_loaded".
+func (h *Host) LoadGoDashboard(d *freeboard.Dashboard, callback func()) error {
+	h.ClearDashboard()
+	h.Lock()
+	h.allowEdit = d.AllowEdit
+	if d.Columns > 0 {
+		h.columns = d.Columns
+	}
+	h.Unlock()
+	for _, ds := range d.Datasources {
+		if _, err := h.NewDatasource(ds.Name, ds.Type, ds.Settings); err != nil {
+			return err
+		}
+	}
+	for _, pane := range d.Panes {
+		h.Lock()
+		h.panes = append(h.panes, pane)
+		h.Unlock()
+		for _, wd := range pane.Widgets {
+			w, err := h.NewWidget(wd.Type, wd.Settings)
+			if err != nil {
+				return err
+			}
+			for setting, v := range wd.Settings {
+				expr, ok := v.(string)
+				if !ok {
+					continue
+				}
+				if name, path, ok := parseBinding(expr); ok {
+					if ds := h.Datasource(name); ds != nil {
+						w.Bind(setting, ds, path...)
+					}
+				}
+			}
+		}
+	}
+	freeboard.FireEvent(freeboard.EventData{Event: freeboard.EventDashboardLoaded})
+	if callback != nil {
+		callback()
+	}
+	return nil
+}
+
+// LoadDashboardSync satisfies freeboard.Host.
+func (h *Host) LoadDashboardSync(d *freeboard.Dashboard) error {
+	return h.LoadGoDashboard(d, nil)
+}
+
+// SetEditMode satisfies freeboard.Host, firing EventEditModeChanged if
+// the mode changes.
+func (h *Host) SetEditMode(editing, animate bool) error {
+	h.Lock()
+	changed := h.editing != editing
+	h.editing = editing
+	h.Unlock()
+	if changed {
+		freeboard.FireEvent(freeboard.EventData{Event: freeboard.EventEditModeChanged, Editing: editing})
+	}
+	return nil
+}
+
+// EditMode satisfies freeboard.Host.
+func (h *Host) EditMode() (bool, error) {
+	h.Lock()
+	defer h.Unlock()
+	return h.editing, nil
+}
+
+// ShowLoading satisfies freeboard.Host.
+func (h *Host) ShowLoading(show bool) error {
+	h.Lock()
+	defer h.Unlock()
+	h.loading = show
+	return nil
+}
+
+// LoadingIndicatorShown reports whether the loading indicator is shown.
+func (h *Host) LoadingIndicatorShown() bool {
+	h.Lock()
+	defer h.Unlock()
+	return h.loading
+}
+
+// OpenDialog satisfies freeboard.Host, recording the dialog's title.
+// Nothing is shown, and neither button is pressed.
+func (h *Host) OpenDialog(contentElement dom.HTMLElement, title, okButtonTitle, cancelButtonTitle string, okCallback interface{}) error {
+	h.Lock()
+	defer h.Unlock()
+	h.dialogs = append(h.dialogs, title)
+	return nil
+}
+
+// Dialogs returns the titles of the dialogs shown, in order.
+func (h *Host) Dialogs() []string {
+	h.Lock()
+	defer h.Unlock()
+	return append([]string(nil), h.dialogs...)
+}
+
+// DatasourceSettings satisfies freeboard.Host, returning nil if there's
+// no such datasource.
+func (h *Host) DatasourceSettings(name string) (map[string]interface{}, error) {
+	ds := h.Datasource(name)
+	if ds == nil {
+		return nil, nil
+	}
+	var settings map[string]interface{}
+	if err := jsToGo(ds.Settings(), &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// UpdateDatasourceSettings satisfies freeboard.Host.
+func (h *Host) UpdateDatasourceSettings(name string, settings map[string]interface{}) error {
+	ds := h.Datasource(name)
+	if ds == nil {
+		return errors.New("freeboardtest: no datasource " + strconv.Quote(name))
+	}
+	return ds.SetSettings(settings)
+}
+
+// Subscribe satisfies freeboard.Host.
+func (h *Host) Subscribe(event freeboard.Event, fn func(freeboard.EventData)) *freeboard.Subscription {
+	return freeboard.SubscribeEvent(event, fn)
+}
@@ -0,0 +1,207 @@
+//go:build js
+// +build js
+
+package freeboardtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cathalgarvey/go-freeboard"
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// counter is a datasource that sends how many times it has updated,
+// under its label.
+type counter struct {
+	settings *js.Object
+	update   func(interface{})
+	n        int
+}
+
+func (c *counter) OnSettingsChanged(settings *js.Object) { c.settings = settings }
+func (c *counter) CurrentSettings() *js.Object           { return c.settings }
+func (c *counter) OnDispose()                            {}
+
+func (c *counter) UpdateNow() {
+	c.n++
+	c.update(map[string]interface{}{"label": c.settings.Get("label").String(), "count": c.n})
+}
+
+var counterDefinition = freeboard.DsPluginDefinition{
+	TypeName: "counter",
+	Settings: []freeboard.FBSetting{
+		freeboard.FBSetting{Name: "label", Type: freeboard.SettingTextType, DefaultStringValue: "count"},
+	},
+	NewInstance: func(settings *js.Object, updateCallback func(interface{})) freeboard.DsPlugin {
+		return &counter{settings: settings, update: updateCallback}
+	},
+}
+
+// recorder is a widget that keeps the calculated values it's given.
+type recorder struct {
+	values   map[string]interface{}
+	disposed bool
+}
+
+// recorders are the recorders made, newest last.
+var recorders []*recorder
+
+func (r *recorder) OnSettingsChanged(*js.Object) {}
+func (r *recorder) Render(dom.HTMLElement)       {}
+func (r *recorder) GetHeight() int               { return 1 }
+func (r *recorder) OnDispose()                   { r.disposed = true }
+
+func (r *recorder) OnCalculatedValueChanged(settingName string, newValue interface{}) {
+	r.values[settingName] = newValue
+}
+
+var recorderDefinition = freeboard.WtPluginDefinition{
+	TypeName: "recorder",
+	Settings: []freeboard.FBSetting{
+		freeboard.FBSetting{Name: "value", Type: freeboard.SettingCalculatedType},
+	},
+	NewInstance: func(settings *js.Object) freeboard.WidgetPlugin {
+		r := &recorder{values: make(map[string]interface{})}
+		recorders = append(recorders, r)
+		return r
+	},
+}
+
+// payload is what counter sends.
+type payload struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+func TestDatasource(t *testing.T) {
+	h := NewHost()
+	h.AddDatasourcePlugin(counterDefinition)
+	ds, err := h.NewDatasource("hits", "counter", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label := ds.Settings().Get("label").String(); label != "count" {
+		t.Errorf("label is %q, want the default", label)
+	}
+	if _, err := h.NewDatasource("hits", "counter", nil); err == nil {
+		t.Error("made a second datasource called hits")
+	}
+	if _, err := h.NewDatasource("x", "nope", nil); err == nil {
+		t.Error("made a datasource of an unknown type")
+	}
+
+	if err := ds.UpdateNow(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.SetSettings(map[string]interface{}{"label": "visits"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.UpdateNow(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.WaitForPayloads(2, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []payload{{"count", 1}, {"visits", 2}} {
+		var got payload
+		if err := ds.DecodePayload(i, &got); err != nil || got != want {
+			t.Errorf("payload %d is %+v, %v, want %+v", i, got, err, want)
+		}
+	}
+	settings, err := h.DatasourceSettings("hits")
+	if err != nil || settings["label"] != "visits" {
+		t.Errorf("DatasourceSettings gives %v, %v", settings, err)
+	}
+
+	if err := ds.Dispose(); err != nil {
+		t.Fatal(err)
+	}
+	if !ds.Disposed() || h.Datasource("hits") != nil || len(h.Datasources()) != 0 {
+		t.Error("the datasource is still on the board after Dispose")
+	}
+	if late := ds.UpdatesAfterDispose(); len(late) != 0 {
+		t.Errorf("%d payloads after Dispose", len(late))
+	}
+}
+
+func TestWidget(t *testing.T) {
+	h := NewHost()
+	h.AddDatasourcePlugin(counterDefinition)
+	h.AddWidgetPlugin(recorderDefinition)
+	ds, err := h.NewDatasource("hits", "counter", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := h.NewWidget("recorder", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := recorders[len(recorders)-1]
+
+	if err := w.SetValue("value", "direct"); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.values["value"]; got != "direct" {
+		t.Errorf("SetValue gave the widget %v", got)
+	}
+
+	w.Bind("value", ds, "count")
+	if err := ds.UpdateNow(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.WaitForPayloads(1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.values["value"]; got != float64(1) {
+		t.Errorf("the bound value is %v, want 1", got)
+	}
+
+	if err := w.Dispose(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.disposed || !w.Disposed() {
+		t.Error("the widget wasn't disposed of")
+	}
+	if err := w.SetValue("value", 2); err == nil {
+		t.Error("SetValue succeeded after Dispose")
+	}
+}
+
+func TestLoadDashboard(t *testing.T) {
+	h := NewHost()
+	h.AddDatasourcePlugin(counterDefinition)
+	h.AddWidgetPlugin(recorderDefinition)
+	d, err := freeboard.NewDashboardBuilder(2).
+		DatasourceType(counterDefinition).
+		WidgetType(recorderDefinition).
+		Datasource("hits", "counter", map[string]interface{}{"label": "visits"}).
+		Pane("Hits", 1).
+		Widget("recorder", map[string]interface{}{"value": freeboard.Bind("hits", "label")}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.LoadDashboardSync(d); err != nil {
+		t.Fatal(err)
+	}
+	r := recorders[len(recorders)-1]
+	if err := h.Datasource("hits").UpdateNow(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Datasource("hits").WaitForPayloads(1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.values["value"]; got != "visits" {
+		t.Errorf("the bound value is %v, want visits", got)
+	}
+
+	saved, err := h.SerializeDashboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds := saved.Datasource("hits"); ds == nil || ds.Settings["label"] != "visits" || len(saved.Panes) != 1 || len(saved.Panes[0].Widgets) != 1 {
+		t.Errorf("the board serialises as %+v", saved)
+	}
+}
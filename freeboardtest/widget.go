@@ -0,0 +1,204 @@
+package freeboardtest
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Widget is an instance of a widget plugin on a Host, made with
+// Host.NewWidget.
+type Widget struct {
+	TypeName string
+
+	host     *Host
+	pane     int
+	mu       sync.Mutex
+	settings *js.Object
+	instance *js.Object
+	values   map[string]interface{}
+	disposed bool
+}
+
+// newWidget calls the plugin's newInstance, as freeboard does.
+func newWidget(h *Host, typeName string, plugin, settings *js.Object, pane int) (*Widget, error) {
+	w := &Widget{TypeName: typeName, host: h, pane: pane, settings: settings, values: make(map[string]interface{})}
+	newInstanceCallback := js.MakeFunc(func(this *js.Object, args []*js.Object) interface{} {
+		if len(args) > 0 {
+			w.mu.Lock()
+			w.instance = args[0]
+			w.mu.Unlock()
+		}
+		return nil
+	})
+	err := callJS(func() { plugin.Call("newInstance", settings, newInstanceCallback) })
+	if err == nil && w.Instance() == nil {
+		err = errors.New("freeboardtest: " + strconv.Quote(typeName) + " didn't make an instance")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Instance returns the object the plugin gave freeboard: for a Go
+// widget, its wrapped WidgetPlugin, with "render", "getHeight" and so on.
+func (w *Widget) Instance() *js.Object {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.instance
+}
+
+// Settings returns the instance's settings, the same object that was
+// given to it.
+func (w *Widget) Settings() *js.Object {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.settings
+}
+
+// SetSettings replaces the instance's settings and calls its
+// onSettingsChanged, as freeboard does when the settings dialog is
+// saved. Unlike NewWidget, defaults aren't filled in.
+func (w *Widget) SetSettings(settings map[string]interface{}) error {
+	o, err := jsonToJS(settings)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.settings = o
+	w.mu.Unlock()
+	return w.call("onSettingsChanged", o)
+}
+
+// SetValue gives the instance a new value for one of its calculated
+// settings, as freeboard does when a datasource the setting reads
+// updates.
+func (w *Widget) SetValue(setting string, value interface{}) error {
+	w.mu.Lock()
+	if w.disposed {
+		w.mu.Unlock()
+		return errors.New("freeboardtest: " + strconv.Quote(w.TypeName) + " has been disposed of")
+	}
+	w.values[setting] = value
+	w.mu.Unlock()
+	return w.call("onCalculatedValueChanged", setting, value)
+}
+
+// Value returns the last value given for a calculated setting, or nil.
+func (w *Widget) Value(setting string) interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.values[setting]
+}
+
+// Bind feeds a calculated setting from a datasource: each payload, and
+// the latest one now, if there is one, is followed along path and given
+// to SetValue. Payloads without the path give undefined, as freeboard's
+// calculated settings do.
+func (w *Widget) Bind(setting string, ds *Datasource, path ...string) {
+	feed := func(payload *js.Object) {
+		w.mu.Lock()
+		disposed := w.disposed
+		w.mu.Unlock()
+		if !disposed {
+			w.SetValue(setting, follow(payload, path))
+		}
+	}
+	ds.watch(feed)
+	if payload := ds.LastPayload(); payload != nil {
+		feed(payload)
+	}
+}
+
+// follow returns the value at path within o, or undefined.
+func follow(o *js.Object, path []string) *js.Object {
+	for _, key := range path {
+		if isUndefined(o) {
+			return js.Undefined
+		}
+		o = o.Get(key)
+	}
+	return o
+}
+
+// bindingPattern matches calculated settings that only read a field of
+// a datasource, such as datasources["weather"]["temp"], as Bind makes.
+var bindingPattern = regexp.MustCompile(`^\s*datasources\["([^"]+)"\]((?:\["[^"]*"\])*)\s*$`)
+
+// keyPattern matches one ["key"] of a binding's path.
+var keyPattern = regexp.MustCompile(`\["([^"]*)"\]`)
+
+// parseBinding returns the datasource and path a calculated setting
+// reads, if it's that simple.
+func parseBinding(expr string) (name string, path []string, ok bool) {
+	m := bindingPattern.FindStringSubmatch(expr)
+	if m == nil {
+		return "", nil, false
+	}
+	for _, key := range keyPattern.FindAllStringSubmatch(m[2], -1) {
+		path = append(path, key[1])
+	}
+	return m[1], path, true
+}
+
+// Render makes a DIV and calls the instance's render with it, as
+// freeboard does once the widget is on the board, and returns the DIV.
+// It needs a DOM, such as jsdom's.
+func (w *Widget) Render() (*js.Object, error) {
+	document := js.Global.Get("document")
+	if isUndefined(document) {
+		return nil, errors.New("freeboardtest: rendering needs a DOM")
+	}
+	container := document.Call("createElement", "div")
+	return container, w.call("render", container)
+}
+
+// Height calls the instance's getHeight.
+func (w *Widget) Height() (height int, err error) {
+	err = callJS(func() { height = w.Instance().Call("getHeight").Int() })
+	return height, err
+}
+
+// Resize calls the instance's onSizeChanged, as freeboard does when the
+// layout changes, if the widget is a ResizableWidget.
+func (w *Widget) Resize() error {
+	if isUndefined(w.Instance().Get("onSizeChanged")) {
+		return nil
+	}
+	return w.call("onSizeChanged")
+}
+
+// Dispose calls the instance's onDispose and takes it off the board, as
+// freeboard does when the widget is deleted. Bound datasources no longer
+// feed it.
+func (w *Widget) Dispose() error {
+	w.mu.Lock()
+	if w.disposed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.disposed = true
+	w.mu.Unlock()
+	w.host.remove(nil, w)
+	return w.call("onDispose")
+}
+
+// Disposed reports whether Dispose has been called.
+func (w *Widget) Disposed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.disposed
+}
+
+// call calls one of the instance's methods.
+func (w *Widget) call(method string, args ...interface{}) error {
+	instance := w.Instance()
+	if isUndefined(instance.Get(method)) {
+		return errors.New("freeboardtest: " + strconv.Quote(w.TypeName) + " has no " + method)
+	}
+	return callJS(func() { instance.Call(method, args...) })
+}
@@ -0,0 +1,278 @@
+package freeboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Gauge geometry, in SVG user units: a semicircle centred on
+// (gaugeCX, gaugeCY) with radius gaugeR, in a viewBox of gaugeW by gaugeH.
+const (
+	gaugeW  = 200.0
+	gaugeH  = 125.0
+	gaugeCX = 100.0
+	gaugeCY = 105.0
+	gaugeR  = 80.0
+)
+
+// gaugeDefaultColour is used for the value arc outside all bands.
+const gaugeDefaultColour = "#ff9900"
+
+// gaugeBand is a coloured threshold band, from the thresholds setting.
+type gaugeBand struct {
+	from, to float64
+	colour   string
+}
+
+// gaugeWidget is the instance type of GaugeWidget.
+type gaugeWidget struct {
+	*BaseWidget
+	// built is the settings object the SVG skeleton was built for.
+	built *js.Object
+	// bands are from the thresholds setting, and rules match them.
+	bands []gaugeBand
+	rules []Rule
+}
+
+// OnSettingsChanged satisfies the WidgetPlugin interface.
+func (gw *gaugeWidget) OnSettingsChanged(settings *js.Object) {
+	gw.setBands(settings)
+	gw.SetHeight(gaugeWidgetHeight(settings))
+	gw.BaseWidget.OnSettingsChanged(settings)
+}
+
+// Draw builds the gauge when the settings change, then moves the needle
+// or arc to the value. Moving the existing elements lets CSS animate it.
+func (gw *gaugeWidget) Draw(state WidgetState) {
+	s := state.Settings
+	min := settingFloat(s, "min", 0)
+	max := settingFloat(s, "max", 100)
+	gw.mu.Lock()
+	bands, rules := gw.bands, gw.rules
+	gw.mu.Unlock()
+	if gw.built != s || state.Container.QuerySelector(".gfb-gauge") == nil {
+		state.Container.SetInnerHTML(gaugeSVG(s, min, max, bands))
+		gw.built = s
+	}
+
+	v, ok := toFloat(state.Values["value"])
+	frac := 0.0
+	text := displayValue(state.Values["value"])
+	colour := gaugeDefaultColour
+	if ok {
+		if max > min {
+			frac = math.Max(0, math.Min(1, (v-min)/(max-min)))
+		}
+		decimals := -1
+		if settingString(s, "decimals") != "" {
+			decimals = int(settingFloat(s, "decimals", -1))
+		}
+		text = FormatNumber(v, decimals, false)
+		if res := evaluateRules(rules, v); res.Rule >= 0 {
+			colour = bands[len(bands)-1-res.Rule].colour
+		}
+	}
+
+	if needle := state.Container.QuerySelector(".gfb-gauge-needle"); needle != nil {
+		needle.Underlying().Get("style").Set("transform", fmt.Sprintf("rotate(%.2fdeg)", frac*180))
+	}
+	if arc := state.Container.QuerySelector(".gfb-gauge-value-arc"); arc != nil {
+		arc.SetAttribute("stroke-dasharray", fmt.Sprintf("%.2f 100", frac*100))
+		arc.SetAttribute("stroke", colour)
+	}
+	if label := state.Container.QuerySelector(".gfb-gauge-value"); label != nil {
+		label.SetTextContent(text)
+	}
+}
+
+// setBands reads the thresholds setting. Each band is matched by a
+// range rule including both ends; the rules are in reverse order, with
+// the same severity, so that the last band to match wins.
+func (gw *gaugeWidget) setBands(s *js.Object) {
+	var bands []gaugeBand
+	var errs []error
+	for i, row := range settingRows(s, "thresholds") {
+		b := gaugeBand{
+			from:   settingFloat(row, "from", math.Inf(-1)),
+			to:     settingFloat(row, "to", math.Inf(1)),
+			colour: settingString(row, "colour"),
+		}
+		if b.from > b.to {
+			errs = append(errs, rowError(i, errors.New("from is above to")))
+			continue
+		}
+		bands = append(bands, b)
+	}
+	rules := make([]Rule, len(bands))
+	for i, b := range bands {
+		rules[len(bands)-1-i] = Rule{Kind: RuleRange, Min: b.from, Max: math.Nextafter(b.to, math.Inf(1)), Severity: SeverityWarning}
+	}
+	gw.mu.Lock()
+	gw.bands, gw.rules = bands, rules
+	gw.mu.Unlock()
+	gw.SetErrors(errs)
+}
+
+// gaugePoint is the point on the gauge's arc, at radius r, for a
+// fraction of the way from min to max.
+func gaugePoint(frac, r float64) (float64, float64) {
+	theta := math.Pi * (1 - frac)
+	return gaugeCX + r*math.Cos(theta), gaugeCY - r*math.Sin(theta)
+}
+
+// gaugeArc is an SVG path along the arc between two fractions.
+func gaugeArc(from, to, r float64) string {
+	x1, y1 := gaugePoint(from, r)
+	x2, y2 := gaugePoint(to, r)
+	return fmt.Sprintf("M %.2f %.2f A %.2f %.2f 0 0 1 %.2f %.2f", x1, y1, r, r, x2, y2)
+}
+
+// gaugeSVG builds the static parts of the gauge: title, track, bands,
+// the needle or value arc at zero, and the min, max, units and label text.
+func gaugeSVG(s *js.Object, min, max float64, bands []gaugeBand) string {
+	var b bytes.Buffer
+	if title := settingString(s, "title"); title != "" {
+		fmt.Fprintf(&b, `<h2 class="section-title">%s</h2>`, html.EscapeString(title))
+	}
+	// The SVG scales to fill the blocks given by the size setting,
+	// less room for the title.
+	fmt.Fprintf(&b, `<svg class="gfb-gauge" viewBox="0 0 %g %g" width="100%%" height="%d" preserveAspectRatio="xMidYMid meet">`,
+		gaugeW, gaugeH, gaugeWidgetHeight(s)*45-30)
+	fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="#3d3d3d" stroke-width="14"/>`, gaugeArc(0, 1, gaugeR))
+	for _, band := range bands {
+		if max <= min {
+			break
+		}
+		from := math.Max(0, math.Min(1, (band.from-min)/(max-min)))
+		to := math.Max(0, math.Min(1, (band.to-min)/(max-min)))
+		if to <= from {
+			continue
+		}
+		fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="%s" stroke-width="4"/>`,
+			gaugeArc(from, to, gaugeR+11), html.EscapeString(band.colour))
+	}
+	if settingString(s, "style") == "needle" {
+		x, y := gaugePoint(0, gaugeR-4)
+		fmt.Fprintf(&b, `<line class="gfb-gauge-needle" x1="%g" y1="%g" x2="%.2f" y2="%.2f" stroke="#d3d4d4" stroke-width="3" stroke-linecap="round" `+
+			`style="transform-origin: %gpx %gpx; transition: transform 0.6s ease-out"/>`, gaugeCX, gaugeCY, x, y, gaugeCX, gaugeCY)
+		fmt.Fprintf(&b, `<circle cx="%g" cy="%g" r="6" fill="#d3d4d4"/>`, gaugeCX, gaugeCY)
+	} else {
+		fmt.Fprintf(&b, `<path class="gfb-gauge-value-arc" d="%s" fill="none" stroke="%s" stroke-width="14" pathLength="100" `+
+			`stroke-dasharray="0 100" style="transition: stroke-dasharray 0.6s ease-out, stroke 0.6s"/>`, gaugeArc(0, 1, gaugeR), gaugeDefaultColour)
+	}
+	valueY := gaugeCY - 20
+	if settingString(s, "style") == "needle" {
+		valueY = gaugeCY - 35
+	}
+	fmt.Fprintf(&b, `<text class="gfb-gauge-value" x="%g" y="%g" text-anchor="middle" font-size="26" fill="#d3d4d4"></text>`, gaugeCX, valueY)
+	if units := settingString(s, "units"); units != "" {
+		fmt.Fprintf(&b, `<text x="%g" y="%g" text-anchor="middle" font-size="12" fill="#8b8b8b">%s</text>`, gaugeCX, valueY+16, html.EscapeString(units))
+	}
+	lx, _ := gaugePoint(0, gaugeR)
+	rx, _ := gaugePoint(1, gaugeR)
+	fmt.Fprintf(&b, `<text x="%.2f" y="%g" text-anchor="middle" font-size="11" fill="#8b8b8b">%s</text>`, lx, gaugeCY+16, html.EscapeString(FormatNumber(min, -1, false)))
+	fmt.Fprintf(&b, `<text x="%.2f" y="%g" text-anchor="middle" font-size="11" fill="#8b8b8b">%s</text>`, rx, gaugeCY+16, html.EscapeString(FormatNumber(max, -1, false)))
+	if label := settingString(s, "label"); label != "" {
+		fmt.Fprintf(&b, `<text x="%g" y="%g" text-anchor="middle" font-size="12" fill="#d3d4d4">%s</text>`, gaugeCX, gaugeCY+16, html.EscapeString(label))
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// gaugeWidgetHeight is 3 blocks for a regular gauge and 5 for a big one.
+func gaugeWidgetHeight(settings *js.Object) int {
+	if settingString(settings, "size") == "big" {
+		return 5
+	}
+	return 3
+}
+
+// GaugeWidget draws a calculated value on a semicircular gauge, as SVG
+// built in Go with no external scripts. The value is shown by an animated
+// needle or arc, against optional coloured threshold bands.
+var GaugeWidget = WtPluginDefinition{
+	TypeName:    "gofreeboard_gauge",
+	DisplayName: "Gauge (Go)",
+	Description: "A gauge with threshold bands, drawn as SVG.",
+	Settings: []FBSetting{
+		FBSetting{
+			Name:        "title",
+			DisplayName: "Title",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "size",
+			DisplayName: "Size",
+			Type:        SettingOptionType,
+			Options: []FBSettingOpt{
+				FBSettingOpt{Name: "Regular", Value: "regular"},
+				FBSettingOpt{Name: "Big", Value: "big"},
+			},
+		},
+		FBSetting{
+			Name:        "value",
+			DisplayName: "Value",
+			Type:        SettingCalculatedType,
+		},
+		FBSetting{
+			Name:        "style",
+			DisplayName: "Style",
+			Type:        SettingOptionType,
+			Options: []FBSettingOpt{
+				FBSettingOpt{Name: "Arc", Value: "arc"},
+				FBSettingOpt{Name: "Needle", Value: "needle"},
+			},
+		},
+		FBSetting{
+			Name:        "min",
+			DisplayName: "Minimum",
+			Type:        SettingNumberType,
+		},
+		FBSetting{
+			Name:            "max",
+			DisplayName:     "Maximum",
+			Type:            SettingNumberType,
+			DefaultIntValue: 100,
+		},
+		FBSetting{
+			Name:        "decimals",
+			DisplayName: "Decimal Places",
+			Description: "Leave blank to use the locale's default.",
+			Type:        SettingNumberType,
+		},
+		FBSetting{
+			Name:        "units",
+			DisplayName: "Units",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "label",
+			DisplayName: "Label",
+			Description: "Text shown under the gauge.",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "thresholds",
+			DisplayName: "Threshold Bands",
+			Description: "Coloured bands from one value to another, e.g. 80 to 100 in #ff0000.",
+			Type:        SettingArrayType,
+			Settings: []FBSettingSet{
+				FBSettingSet{Name: "from", DisplayName: "From", Type: SettingNumberType},
+				FBSettingSet{Name: "to", DisplayName: "To", Type: SettingNumberType},
+				FBSettingSet{Name: "colour", DisplayName: "Colour", Type: SettingTextType},
+			},
+		},
+	},
+	NewInstance: func(settings *js.Object) WidgetPlugin {
+		gw := new(gaugeWidget)
+		gw.BaseWidget = NewBaseWidget(settings, gw.Draw)
+		gw.setBands(settings)
+		gw.SetHeight(gaugeWidgetHeight(settings))
+		return gw
+	},
+}
@@ -0,0 +1,213 @@
+package freeboard
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// PanicError describes a panic recovered from a plugin's method. For a
+// datasource, it is counted in the instance's DsMetrics, fired with
+// EventDatasourceError, and shown on the datasource's row in freeboard's
+// datasource list until its settings change; the datasource's data is
+// left as it was.
+type PanicError struct {
+	// Plugin is the plugin's TypeName, or its Go type.
+	Plugin string
+	// Callback is the name of the method, as freeboard calls it.
+	Callback string
+	// Message describes the value the method panicked with.
+	Message string
+}
+
+func (pe *PanicError) Error() string {
+	return pe.Plugin + "." + pe.Callback + ": " + pe.Message
+}
+
+// pluginGuard recovers panics in the callbacks of one plugin instance, so
+// that a faulty plugin can't stop the rest of the board. A recovered panic
+// is logged to the console with its stack, marks the instance as errored,
+// and is passed to each of the onPanic hooks.
+type pluginGuard struct {
+	sync.Mutex
+	// name identifies the plugin in logs and error messages.
+	name string
+	// errored is set by a panic and cleared by a settings change.
+	errored bool
+	// container is the element a widget was last rendered into.
+	container *js.Object
+	onPanic   []func(err *PanicError)
+}
+
+func newPluginGuard(name string) *pluginGuard {
+	return &pluginGuard{name: name}
+}
+
+// Errored reports whether the instance has panicked since its settings
+// were last changed.
+func (g *pluginGuard) Errored() bool {
+	g.Lock()
+	defer g.Unlock()
+	return g.errored
+}
+
+// recover must be deferred directly. It recovers a panic from the named
+// callback, if there is one.
+func (g *pluginGuard) recover(callback string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	err := &PanicError{Plugin: g.name, Callback: callback, Message: panicMessage(r)}
+	stack := string(debug.Stack())
+	if jsErr, ok := r.(*js.Error); ok && jsErr.Object != nil {
+		stack = jsErr.Get("stack").String()
+	}
+	logError("go-freeboard: recovered panic in "+err.Error()+"\n", stack)
+	g.Lock()
+	g.errored = true
+	hooks := g.onPanic
+	g.Unlock()
+	for _, hook := range hooks {
+		func() {
+			defer func() { recover() }()
+			hook(err)
+		}()
+	}
+}
+
+// settled clears the errored mark.
+func (g *pluginGuard) settled() {
+	g.Lock()
+	g.errored = false
+	g.Unlock()
+}
+
+// guardFuncs replaces the functions of a map made by WrapDsPlugin or
+// WrapWidgetPlugin with versions that recover panics. It panics if a
+// function is of a type it doesn't know how to guard, rather than leave
+// it unguarded.
+func (g *pluginGuard) guardFuncs(wrapper map[string]interface{}) {
+	for name, fn := range wrapper {
+		name := name
+		switch f := fn.(type) {
+		case func():
+			wrapper[name] = func() {
+				defer g.recover(name)
+				f()
+			}
+		case func() int:
+			wrapper[name] = func() (i int) {
+				defer g.recover(name)
+				return f()
+			}
+		case func() *js.Object:
+			wrapper[name] = func() (o *js.Object) {
+				defer g.recover(name)
+				return f()
+			}
+		case func(*js.Object):
+			wrapper[name] = func(o *js.Object) {
+				defer g.recover(name)
+				f(o)
+				if name == "onSettingsChanged" {
+					g.settled()
+				}
+			}
+		case func(string, interface{}):
+			wrapper[name] = func(s string, i interface{}) {
+				defer g.recover(name)
+				f(s, i)
+			}
+		case func(...*js.Object):
+			wrapper[name] = func(args ...*js.Object) {
+				defer g.recover(name)
+				if name == "render" && len(args) > 0 {
+					g.Lock()
+					g.container = args[0]
+					g.Unlock()
+				}
+				f(args...)
+			}
+		default:
+			if t := reflect.TypeOf(fn); t != nil && t.Kind() == reflect.Func {
+				panic("go-freeboard: can't guard " + name + " of type " + t.String())
+			}
+		}
+	}
+}
+
+// showInContainer replaces the contents of the widget's container with
+// the error message. It does nothing if the widget hasn't been rendered.
+func (g *pluginGuard) showInContainer(err *PanicError) {
+	g.Lock()
+	container := g.container
+	g.Unlock()
+	if container == nil || container == js.Undefined {
+		return
+	}
+	el := dom.WrapHTMLElement(container)
+	box := dom.GetWindow().Document().CreateElement("div").(dom.HTMLElement)
+	box.Class().SetString("go-freeboard-error")
+	box.Style().SetProperty("color", "#ff6b6b", "")
+	box.Style().SetProperty("overflow", "hidden", "")
+	box.SetTextContent("⚠ " + err.Error())
+	el.SetInnerHTML("")
+	el.AppendChild(box)
+}
+
+// datasourceErrorClass marks a datasource's name in freeboard's
+// datasource list while it's errored.
+const datasourceErrorClass = "go-freeboard-datasource-error"
+
+// markDatasource marks the named datasource in freeboard's datasource
+// list as errored, with err as its tooltip, or clears the mark if err is
+// nil. It does nothing if the list or the datasource isn't there.
+func markDatasource(name string, err error) {
+	if js.Global == nil || js.Global.Get("document") == js.Undefined {
+		return
+	}
+	for _, el := range dom.GetWindow().Document().QuerySelectorAll("#datasources .datasource-name") {
+		if el.TextContent() != name {
+			continue
+		}
+		if err == nil {
+			el.Class().Remove(datasourceErrorClass)
+			el.RemoveAttribute("title")
+			el.(dom.HTMLElement).Style().RemoveProperty("color")
+			continue
+		}
+		el.Class().Add(datasourceErrorClass)
+		el.SetAttribute("title", "⚠ "+err.Error())
+		el.(dom.HTMLElement).Style().SetProperty("color", "#ff6b6b", "")
+	}
+}
+
+// logError writes to the browser console, or stdout outside a browser.
+func logError(args ...interface{}) {
+	if js.Global == nil {
+		fmt.Println(args...)
+		return
+	}
+	if console := js.Global.Get("console"); console != nil && console != js.Undefined {
+		console.Call("error", args...)
+		return
+	}
+	fmt.Println(args...)
+}
+
+// panicMessage describes a recovered panic value.
+func panicMessage(r interface{}) string {
+	switch v := r.(type) {
+	case error:
+		return v.Error()
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,89 @@
+//go:build js
+// +build js
+
+package freeboard
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// fakeDatasourceList installs a document whose datasource list has a
+// row for each name, and returns the name elements and a func that
+// removes the document again.
+func fakeDatasourceList(names ...string) (*js.Object, func()) {
+	js.Global.Call("eval", `global.document = {
+		names: [],
+		querySelectorAll: function(selector) {
+			return selector === "#datasources .datasource-name" ? this.names : [];
+		}
+	};`)
+	list := js.Global.Get("document").Get("names")
+	for _, name := range names {
+		js.Global.Call("eval", `document.names.push((function(name) {
+			var el = {tagName: "SPAN", textContent: name, className: "datasource-name", attributes: {}, style: {}};
+			el.classList = {
+				add: function(c) { el.className += " " + c; },
+				remove: function(c) { el.className = el.className.split(" ").filter(function(x) { return x !== c; }).join(" "); },
+				contains: function(c) { return el.className.split(" ").indexOf(c) >= 0; }
+			};
+			el.setAttribute = function(k, v) { el.attributes[k] = v; };
+			el.removeAttribute = function(k) { delete el.attributes[k]; };
+			el.style.setProperty = function(k, v) { el.style[k] = v; };
+			el.style.removeProperty = function(k) { delete el.style[k]; };
+			return el;
+		})(`+js.Global.Get("JSON").Call("stringify", name).String()+`))`)
+	}
+	return list, func() { js.Global.Call("eval", "delete global.document") }
+}
+
+func TestMarkDatasource(t *testing.T) {
+	list, remove := fakeDatasourceList("weather", "cats")
+	defer remove()
+	marked := func(i int) bool {
+		return list.Index(i).Get("classList").Call("contains", datasourceErrorClass).Bool()
+	}
+
+	markDatasource("cats", errors.New("cats.updateNow: boom"))
+	if marked(0) || !marked(1) {
+		t.Errorf("marked weather %v and cats %v, want only cats", marked(0), marked(1))
+	}
+	if title := list.Index(1).Get("attributes").Get("title").String(); title != "⚠ cats.updateNow: boom" {
+		t.Errorf("cats's tooltip is %q", title)
+	}
+
+	markDatasource("cats", nil)
+	if marked(1) || list.Index(1).Get("attributes").Get("title") != js.Undefined {
+		t.Error("the mark wasn't cleared")
+	}
+}
+
+func TestDatasourcePanicsAreMarkedUntilSettingsChange(t *testing.T) {
+	list, remove := fakeDatasourceList("cats")
+	defer remove()
+	guard := newPluginGuard("cats")
+	di := newDsInstrument(nil, "cats", nil)
+	// Freeboard's board would give the name; here there's no board.
+	di.metrics.Name = "cats"
+	guard.onPanic = append(guard.onPanic, di.recordPanic)
+	wrapper := wrapDsPlugin(&slowPlugin{}, newUpdateFlight(UpdateSerial, guard), guard)
+	di.instrument(wrapper)
+	defer wrapper["onDispose"].(func())()
+	marked := func() bool {
+		return list.Index(0).Get("classList").Call("contains", datasourceErrorClass).Bool()
+	}
+
+	func() {
+		defer guard.recover("updateNow")
+		panic("boom")
+	}()
+	if !marked() {
+		t.Error("the panic wasn't shown in the datasource list")
+	}
+	wrapper["onSettingsChanged"].(func(*js.Object))(js.Global.Get("Object").New())
+	if marked() {
+		t.Error("the mark outlived a settings change")
+	}
+}
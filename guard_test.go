@@ -0,0 +1,41 @@
+package freeboard
+
+import (
+	"testing"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+func TestGuardRecoversAndMarksErrored(t *testing.T) {
+	g := newPluginGuard("testPlugin")
+	var got []*PanicError
+	g.onPanic = append(g.onPanic, func(err *PanicError) { got = append(got, err) })
+	wrapper := map[string]interface{}{
+		"updateNow":         func() { panic("boom") },
+		"onSettingsChanged": func(*js.Object) {},
+		"plugin":            struct{}{},
+	}
+	g.guardFuncs(wrapper)
+	wrapper["updateNow"].(func())()
+	if len(got) != 1 || got[0].Error() != "testPlugin.updateNow: boom" {
+		t.Fatalf("got %v, want one testPlugin.updateNow: boom", got)
+	}
+	if !g.Errored() {
+		t.Error("not errored after a panic")
+	}
+	wrapper["onSettingsChanged"].(func(*js.Object))(nil)
+	if g.Errored() {
+		t.Error("still errored after new settings")
+	}
+}
+
+func TestGuardFuncsRejectsUnknownFuncs(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("guardFuncs left a func(string) string unguarded without complaint")
+		}
+	}()
+	newPluginGuard("testPlugin").guardFuncs(map[string]interface{}{
+		"format": func(s string) string { return s },
+	})
+}
@@ -0,0 +1,47 @@
+package freeboard
+
+import (
+	"honnef.co/go/js/dom"
+)
+
+// Host is a freeboard board that plugins are loaded into: FBWrapper for
+// the real thing in the browser, or a fake, such as freeboardtest's, in
+// tests. Its methods take and return Go values, and return
+// ErrNoFreeboard if there's no board to act on. FBWrapper also has the
+// methods that take JS objects, for plugins written in JS.
+type Host interface {
+	InitializeBoard(allowEdit bool, finished func()) error
+	InitializeSync(allowEdit bool) error
+	ClearDashboard() error
+	SerializeDashboard() (*Dashboard, error)
+	LoadGoDashboard(d *Dashboard, callback func()) error
+	LoadDashboardSync(d *Dashboard) error
+	SetEditMode(editing, animate bool) error
+	EditMode() (bool, error)
+	AddDatasourcePlugin(ds DsPluginDefinition) error
+	AddWidgetPlugin(wt WtPluginDefinition) error
+	ShowLoading(show bool) error
+	OpenDialog(contentElement dom.HTMLElement, title, okButtonTitle, cancelButtonTitle string, okCallback interface{}) error
+	DatasourceSettings(name string) (map[string]interface{}, error)
+	UpdateDatasourceSettings(name string, settings map[string]interface{}) error
+	Subscribe(event Event, fn func(EventData)) *Subscription
+}
+
+// DefaultHost is the Host given to plugins compiled with ToFBInterface
+// rather than ToFBInterfaceFor, and used to look up datasource names.
+// If it is nil, FB is used.
+var DefaultHost Host
+
+// defaultHost returns DefaultHost, or FB, or nil if there's neither.
+func defaultHost() Host {
+	if DefaultHost != nil {
+		return DefaultHost
+	}
+	if FB != nil {
+		return FB
+	}
+	return nil
+}
+
+// FBWrapper is the browser's Host.
+var _ Host = (*FBWrapper)(nil)
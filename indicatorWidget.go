@@ -0,0 +1,179 @@
+package freeboard
+
+import (
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// indicatorOffColour is the light's colour when no rule matches.
+const indicatorOffColour = "#3d3d3d"
+
+// indicatorCSS makes lights blink; it's added to the page once.
+const indicatorCSS = `@keyframes gfb-blink { 50% { opacity: 0.2; } }
+.gfb-blink { animation: gfb-blink 1s step-start infinite; }`
+
+// indicatorLook is how the light looks when a rule matches.
+type indicatorLook struct {
+	colour string
+	blink  bool
+}
+
+// indicatorWidget is the instance type of IndicatorWidget.
+type indicatorWidget struct {
+	*BaseWidget
+	rules *RuleSet
+	// looks are by rule, as rules.Rules.
+	looks []indicatorLook
+}
+
+// OnSettingsChanged satisfies the WidgetPlugin interface.
+func (iw *indicatorWidget) OnSettingsChanged(settings *js.Object) {
+	iw.setRules(settings)
+	iw.BaseWidget.OnSettingsChanged(settings)
+}
+
+// OnCalculatedValueChanged satisfies the WidgetPlugin interface.
+func (iw *indicatorWidget) OnCalculatedValueChanged(settingName string, newValue interface{}) {
+	if settingName == "value" {
+		iw.rules.Observe(newValue)
+	}
+	iw.BaseWidget.OnCalculatedValueChanged(settingName, newValue)
+}
+
+// setRules reads the rules setting. Every rule has the same severity,
+// so that the first to match wins.
+func (iw *indicatorWidget) setRules(s *js.Object) {
+	var rules []Rule
+	var looks []indicatorLook
+	var errs []error
+	for i, row := range settingRows(s, "rules") {
+		r, err := CompareRule("", settingString(row, "op"), settingString(row, "value"), SeverityWarning)
+		if err != nil {
+			errs = append(errs, rowError(i, err))
+			continue
+		}
+		r.Message = settingString(row, "text")
+		blink := strings.ToLower(strings.TrimSpace(settingString(row, "blink")))
+		rules = append(rules, r)
+		looks = append(looks, indicatorLook{
+			colour: settingString(row, "colour"),
+			blink:  blink == "yes" || blink == "true" || blink == "y" || blink == "1",
+		})
+	}
+	iw.mu.Lock()
+	iw.rules.SetRules(rules)
+	iw.looks = looks
+	iw.mu.Unlock()
+	iw.SetErrors(errs)
+}
+
+// Draw renders the light, coloured by the first matching rule.
+func (iw *indicatorWidget) Draw(state WidgetState) {
+	s := state.Settings
+	v := state.Values["value"]
+	colour, text, blink := indicatorOffColour, settingString(s, "off_text"), false
+	iw.mu.Lock()
+	res := iw.rules.Evaluate()
+	if res.Rule >= 0 && res.Rule < len(iw.looks) {
+		look := iw.looks[res.Rule]
+		colour, text, blink = look.colour, res.Message, look.blink
+		if text == "" {
+			text = settingString(s, "on_text")
+		}
+	}
+	iw.mu.Unlock()
+	text = strings.Replace(text, "{value}", displayValue(v), -1)
+
+	ensureStyle("gfb-indicator-css", indicatorCSS)
+	doc := dom.GetWindow().Document()
+	state.Container.SetInnerHTML("")
+	if title := settingString(s, "title"); title != "" {
+		h := doc.CreateElement("h2").(dom.HTMLElement)
+		h.Class().SetString("section-title")
+		h.SetTextContent(title)
+		state.Container.AppendChild(h)
+	}
+	light := doc.CreateElement("div").(dom.HTMLElement)
+	light.Class().SetString("indicator-light")
+	if colour != indicatorOffColour {
+		light.Class().Add("on")
+	}
+	if blink {
+		light.Class().Add("gfb-blink")
+	}
+	light.Style().SetProperty("background-color", colour, "")
+	light.Style().SetProperty("box-shadow", "0 0 15px "+colour, "")
+	state.Container.AppendChild(light)
+	label := doc.CreateElement("div").(dom.HTMLElement)
+	label.Class().SetString("indicator-text")
+	label.SetTextContent(text)
+	state.Container.AppendChild(label)
+}
+
+// ensureStyle adds a stylesheet to the page, unless one with the
+// same id has been added already.
+func ensureStyle(id, css string) {
+	doc := dom.GetWindow().Document()
+	if doc.GetElementByID(id) != nil {
+		return
+	}
+	style := doc.CreateElement("style")
+	style.SetID(id)
+	style.SetTextContent(css)
+	doc.QuerySelector("head").AppendChild(style)
+}
+
+// IndicatorWidget is an indicator light driven by rules on a calculated
+// value, such as "> 90 is red, > 70 is amber, otherwise green". The first
+// matching rule sets the light's colour and text, and can make it blink.
+var IndicatorWidget = WtPluginDefinition{
+	TypeName:    "gofreeboard_indicator",
+	DisplayName: "Indicator Light (Go)",
+	Description: "A coloured light set by threshold rules.",
+	Settings: []FBSetting{
+		FBSetting{
+			Name:        "title",
+			DisplayName: "Title",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "value",
+			DisplayName: "Value",
+			Type:        SettingCalculatedType,
+		},
+		FBSetting{
+			Name:        "rules",
+			DisplayName: "Rules",
+			Description: "The first rule that matches the value sets the light. Op: " + ComparisonHelp + " Blink: yes or no. Text may include {value}.",
+			Type:        SettingArrayType,
+			Settings: []FBSettingSet{
+				FBSettingSet{Name: "op", DisplayName: "Op", Type: SettingTextType},
+				FBSettingSet{Name: "value", DisplayName: "Value", Type: SettingTextType},
+				FBSettingSet{Name: "colour", DisplayName: "Colour", Type: SettingTextType},
+				FBSettingSet{Name: "text", DisplayName: "Text", Type: SettingTextType},
+				FBSettingSet{Name: "blink", DisplayName: "Blink", Type: SettingTextType},
+			},
+		},
+		FBSetting{
+			Name:        "on_text",
+			DisplayName: "On Text",
+			Description: "Shown when a rule without text of its own matches.",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "off_text",
+			DisplayName: "Off Text",
+			Description: "Shown when no rule matches.",
+			Type:        SettingTextType,
+		},
+	},
+	NewInstance: func(settings *js.Object) WidgetPlugin {
+		iw := new(indicatorWidget)
+		iw.BaseWidget = NewBaseWidget(settings, iw.Draw)
+		iw.rules = NewRuleSet(nil)
+		iw.setRules(settings)
+		return iw
+	},
+}
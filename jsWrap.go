@@ -33,6 +33,10 @@ func jsWrap(fn interface{}) func(...*js.Object) {
 	return func(args ...*js.Object) {
 		in := make([]reflect.Value, v.Type().NumIn())
 		for i := range in {
+			// Missing arguments are passed as JS undefined.
+			if i >= len(args) {
+				args = append(args, js.Undefined)
+			}
 			switch t := v.Type().In(i); t {
 			// *js.Object is passed through.
 			case typeOf((**js.Object)(nil)):
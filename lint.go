@@ -0,0 +1,143 @@
+package freeboard
+
+import (
+	"sort"
+	"strconv"
+)
+
+// DashboardIssue is a problem found by LintDashboard.
+type DashboardIssue struct {
+	// Where names the datasource or widget, e.g. `datasource "weather"`
+	// or `pane "Load", widget 2 (gauge)`.
+	Where   string
+	Problem string
+}
+
+func (di DashboardIssue) String() string {
+	return di.Where + ": " + di.Problem
+}
+
+// LintDashboard checks a dashboard against a set of plugin types. It
+// reports:
+//
+//   - datasources and widgets of types not in the set
+//   - duplicate datasource names
+//   - required settings that are missing or blank
+//   - option settings not set to one of their options, and number
+//     settings that aren't numbers
+//   - calculated settings that refer to datasources that don't exist
+//   - datasources that no widget refers to
+//
+// Settings are only checked for plugins with Go definitions; for plugins
+// written in JS, every string setting of a widget is searched for
+// datasource references.
+func LintDashboard(d *Dashboard, plugins *PluginSet) []DashboardIssue {
+	var issues []DashboardIssue
+	report := func(where, problem string) {
+		issues = append(issues, DashboardIssue{Where: where, Problem: problem})
+	}
+
+	names := make(map[string]bool)
+	for _, ds := range d.Datasources {
+		where := "datasource " + strconv.Quote(ds.Name)
+		if names[ds.Name] {
+			report(where, "duplicate datasource name")
+		}
+		names[ds.Name] = true
+		schema, ok := plugins.datasource(ds.Type)
+		if !ok {
+			report(where, "unknown datasource type "+strconv.Quote(ds.Type))
+			continue
+		}
+		for _, problem := range lintSettings(ds.Settings, schema) {
+			report(where, problem)
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, pane := range d.Panes {
+		for i, w := range pane.Widgets {
+			where := "pane " + strconv.Quote(pane.Title) + ", widget " + strconv.Itoa(i+1) + " (" + w.Type + ")"
+			schema, ok := plugins.widget(w.Type)
+			if !ok {
+				report(where, "unknown widget type "+strconv.Quote(w.Type))
+			}
+			for _, problem := range lintSettings(w.Settings, schema) {
+				report(where, problem)
+			}
+			calculated := w.Settings
+			if schema.isGo {
+				calculated = make(map[string]interface{})
+				for _, set := range schema.settings {
+					if set.Type == SettingCalculatedType {
+						calculated[set.Name] = w.Settings[set.Name]
+					}
+				}
+			}
+			for _, name := range DatasourceRefs(calculated) {
+				if !names[name] {
+					report(where, "refers to missing datasource "+strconv.Quote(name))
+				}
+			}
+			for _, name := range DatasourceRefs(w.Settings) {
+				used[name] = true
+			}
+		}
+	}
+
+	var unused []string
+	for name := range names {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	for _, name := range unused {
+		report("datasource "+strconv.Quote(name), "not used by any widget")
+	}
+	return issues
+}
+
+// lintSettings checks settings against a Go plugin's definitions.
+func lintSettings(settings map[string]interface{}, schema pluginSchema) []string {
+	if !schema.isGo {
+		return nil
+	}
+	var problems []string
+	for _, set := range schema.settings {
+		v := settings[set.Name]
+		if v == nil || v == "" {
+			if set.Required {
+				problems = append(problems, "required setting "+strconv.Quote(set.Name)+" is missing")
+			}
+			continue
+		}
+		switch set.Type {
+		case SettingOptionType:
+			value := displayValue(v)
+			valid := false
+			for _, opt := range set.Options {
+				optValue := opt.Value
+				if optValue == "" {
+					optValue = opt.Name
+				}
+				if value == optValue {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				problems = append(problems, "setting "+strconv.Quote(set.Name)+" is "+strconv.Quote(value)+", which isn't one of its options")
+			}
+		case SettingNumberType:
+			if _, ok := toFloat(v); !ok {
+				problems = append(problems, "setting "+strconv.Quote(set.Name)+" is "+strconv.Quote(displayValue(v))+", which isn't a number")
+			}
+		case SettingArrayType:
+			if _, ok := v.([]interface{}); !ok {
+				problems = append(problems, "setting "+strconv.Quote(set.Name)+" should be a list of rows")
+			}
+		}
+	}
+	return problems
+}
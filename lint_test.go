@@ -0,0 +1,122 @@
+package freeboard
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lintPlugins are the plugins the lint tests check against: freeboard's
+// own, and some described in JSON.
+const lintPlugins = `{
+	"datasources": [{"type_name": "weather", "settings": [
+		{"name": "city", "type": "text", "required": true},
+		{"name": "units", "type": "option", "options": [{"name": "Metric", "value": "metric"}, {"name": "imperial"}]},
+		{"name": "refresh", "type": "number", "default_value": 5}
+	]}],
+	"widgets": [{"type_name": "dial", "settings": [
+		{"name": "title", "type": "text"},
+		{"name": "value", "type": "calculated", "required": true},
+		{"name": "bands", "type": "array", "settings": [{"name": "from", "type": "number"}]},
+		{"name": "note", "type": "text"}
+	]}]
+}`
+
+func TestLintDashboard(t *testing.T) {
+	plugins := NewPluginSet().AddFreeboard()
+	if err := plugins.AddDefinitions([]byte(lintPlugins)); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name  string
+		board string
+		want  []string
+	}{
+		{
+			"clean",
+			`{"datasources": [{"name": "w", "type": "weather", "settings": {"city": "Cork", "units": "imperial", "refresh": "10"}}],
+			"panes": [{"title": "p", "widgets": [{"type": "dial", "settings": {"value": "datasources[\"w\"][\"temp\"]", "bands": []}}]}]}`,
+			nil,
+		},
+		{
+			"unknown types",
+			`{"datasources": [{"name": "x", "type": "nope"}],
+			"panes": [{"title": "p", "widgets": [{"type": "nada", "settings": {"v": "datasources.x"}}]}]}`,
+			[]string{
+				`datasource "x": unknown datasource type "nope"`,
+				`pane "p", widget 1 (nada): unknown widget type "nada"`,
+			},
+		},
+		{
+			"bad settings",
+			`{"datasources": [{"name": "w", "type": "weather", "settings": {"city": " ", "units": "kelvin", "refresh": "often"}}],
+			"panes": [{"title": "p", "widgets": [{"type": "dial", "settings": {"value": "datasources.w.t", "bands": "1-2"}}]}]}`,
+			[]string{
+				`datasource "w": setting "units" is "kelvin", which isn't one of its options`,
+				`datasource "w": setting "refresh" is "often", which isn't a number`,
+				`pane "p", widget 1 (dial): setting "bands" should be a list of rows`,
+			},
+		},
+		{
+			"missing required",
+			`{"datasources": [{"name": "w", "type": "weather", "settings": {}}],
+			"panes": [{"title": "p", "widgets": [{"type": "dial", "settings": {"title": "datasources.w"}}]}]}`,
+			[]string{
+				`datasource "w": required setting "city" is missing`,
+				`pane "p", widget 1 (dial): required setting "value" is missing`,
+			},
+		},
+		{
+			"missing and unused datasources",
+			`{"datasources": [{"name": "clock", "type": "clock"}, {"name": "clock", "type": "clock"}, {"name": "spare", "type": "clock"}],
+			"panes": [{"title": "p", "widgets": [
+				{"type": "dial", "settings": {"value": "datasources.gone", "note": "datasources.ignored"}},
+				{"type": "text_widget", "settings": {"value": "datasources[\"clock\"].t", "title": "datasources.elsewhere"}}
+			]}]}`,
+			[]string{
+				`datasource "clock": duplicate datasource name`,
+				`pane "p", widget 1 (dial): refers to missing datasource "gone"`,
+				`pane "p", widget 2 (text_widget): refers to missing datasource "elsewhere"`,
+				`datasource "spare": not used by any widget`,
+			},
+		},
+	} {
+		d, err := ParseDashboard([]byte(tc.board))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var got []string
+		for _, issue := range LintDashboard(d, plugins) {
+			got = append(got, issue.String())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPluginSetAddDefinitions(t *testing.T) {
+	ps := NewPluginSet()
+	if err := ps.AddDefinitions([]byte(lintPlugins)); err != nil {
+		t.Fatal(err)
+	}
+	schema, ok := ps.datasource("weather")
+	if !ok || !schema.isGo || len(schema.settings) != 3 {
+		t.Fatalf("weather is %+v", schema)
+	}
+	refresh := schema.settings[2]
+	if v, ok, err := refresh.defaultValue(); !ok || err != nil || v != 5 {
+		t.Errorf("refresh defaults to %v, %v, %v", v, ok, err)
+	}
+	if opts := schema.settings[1].Options; len(opts) != 2 || opts[1].Name != "imperial" {
+		t.Errorf("units has options %+v", opts)
+	}
+	for _, bad := range []string{
+		`{"widgets": [{"settings": []}]}`,
+		`{"widgets": [{"type_name": "w", "settings": [{"name": "s", "type": "colour"}]}]}`,
+		`{"widgets": {}}`,
+	} {
+		if err := NewPluginSet().AddDefinitions([]byte(bad)); err == nil {
+			t.Errorf("AddDefinitions(%s) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package freeboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// persistKeyPrefix namespaces saved dashboards within localStorage.
+const persistKeyPrefix = "go-freeboard:dashboard:"
+
+// Persistence defaults.
+const (
+	DefaultPersistVersions = 5
+	DefaultPersistDebounce = 2 * time.Second
+)
+
+// revertActionID is the id of the toolbar item added by Start.
+const revertActionID = "gofreeboard-revert"
+
+// PersistedVersion is an earlier version of a persisted board.
+type PersistedVersion struct {
+	// SavedAt is when the board was saved. It's zero for boards saved
+	// before save times were kept.
+	SavedAt time.Time `json:"saved_at"`
+	// Data is the serialised board, as JSON.
+	Data json.RawMessage `json:"dashboard"`
+}
+
+// Dashboard parses the version's board.
+func (pv PersistedVersion) Dashboard() (*Dashboard, error) {
+	return ParseDashboard(pv.Data)
+}
+
+// Persistence keeps a board in localStorage, so that it survives the
+// browser restarting. Once it's enabled with FBWrapper.Persist,
+// Initialize restores the saved board, and from then on edits are saved
+// a short while after they stop, and straight away on leaving edit mode.
+// Freeboard has no event for edits, so while the board is in edit mode,
+// found through EventEditModeChanged, it is serialised every Debounce
+// and compared with the last; outside edit mode nothing is checked. The
+// board is also saved when one is loaded, e.g. from a file.
+//
+// The board saved before each save is kept as an earlier version, to
+// RevertToPrevious, which is offered as "Revert to Previous" in the
+// board's toolbar.
+//
+// Without localStorage, e.g. in a private window, nothing is saved, and
+// the board works as usual.
+type Persistence struct {
+	sync.Mutex
+	fb  *FBWrapper
+	key string
+	// Versions is how many earlier versions to keep.
+	Versions int
+	// Debounce is how long the board must go unedited before it's saved,
+	// and so how often it's checked for edits in edit mode.
+	Debounce time.Duration
+
+	lastSaved, lastSeen string
+	subs                []*Subscription
+	closeToKillTicker   chan interface{}
+}
+
+// persistences maps wrappers to their Persistence, for Initialize.
+var persistences = struct {
+	sync.Mutex
+	m map[*FBWrapper]*Persistence
+}{m: make(map[*FBWrapper]*Persistence)}
+
+// Persist enables persistence of the board to localStorage under the
+// given name, restoring it and starting autosave when Initialize is
+// next called. Call it before Initialize; calling it again replaces the
+// earlier Persistence.
+func (fb *FBWrapper) Persist(name string) *Persistence {
+	p := &Persistence{
+		fb:       fb,
+		key:      persistKeyPrefix + name,
+		Versions: DefaultPersistVersions,
+		Debounce: DefaultPersistDebounce,
+	}
+	persistences.Lock()
+	if old := persistences.m[fb]; old != nil {
+		old.Stop()
+	}
+	persistences.m[fb] = p
+	persistences.Unlock()
+	return p
+}
+
+// persistenceFor returns the Persistence enabled on fb, or nil.
+func persistenceFor(fb *FBWrapper) *Persistence {
+	persistences.Lock()
+	defer persistences.Unlock()
+	return persistences.m[fb]
+}
+
+// restore loads the saved board, if there is one, starts autosave, and
+// then calls finished, which may be nil.
+func (p *Persistence) restore(finished func()) {
+	done := func() {
+		p.Start()
+		if finished != nil {
+			finished()
+		}
+	}
+	ls := localStorage()
+	if ls == nil {
+		done()
+		return
+	}
+	p.Lock()
+	saved, ok := p.current(ls)
+	p.Unlock()
+	if !ok {
+		done()
+		return
+	}
+	p.load(string(saved.Data), done)
+}
+
+// load loads a serialised board, noting it as saved so that autosave
+// doesn't store it again.
+func (p *Persistence) load(serialised string, callback func()) {
+	p.Lock()
+	p.lastSaved, p.lastSeen = serialised, serialised
+	p.Unlock()
+	if err := p.fb.loadJSON(serialised, callback); err != nil {
+		logError(p.key, err.Error())
+	}
+}
+
+// Start starts autosave, if it isn't running already, and adds the
+// revert action to the toolbar. Initialize starts it, so this is only
+// needed after Stop.
+func (p *Persistence) Start() {
+	p.Lock()
+	if p.subs != nil {
+		p.Unlock()
+		return
+	}
+	p.subs = []*Subscription{
+		p.fb.Subscribe(EventEditModeChanged, p.editModeChanged),
+		p.fb.Subscribe(EventDashboardLoaded, func(EventData) { p.saveIfChanged() }),
+	}
+	p.Unlock()
+	if editing, _ := p.fb.EditMode(); editing {
+		p.editModeChanged(EventData{Event: EventEditModeChanged, Editing: true})
+	}
+	p.addRevertAction()
+}
+
+// Stop stops autosave and removes the revert action. The saved board
+// and versions are kept.
+func (p *Persistence) Stop() {
+	p.Lock()
+	for _, sub := range p.subs {
+		sub.Unsubscribe()
+	}
+	p.subs = nil
+	if p.closeToKillTicker != nil {
+		close(p.closeToKillTicker)
+		p.closeToKillTicker = nil
+	}
+	p.Unlock()
+	if document := js.Global.Get("document"); document != js.Undefined {
+		if el := document.Call("getElementById", revertActionID); el != nil {
+			el.Get("parentNode").Call("removeChild", el)
+		}
+	}
+}
+
+// editModeChanged starts checking for edits on entering edit mode, and
+// stops and saves on leaving it.
+func (p *Persistence) editModeChanged(data EventData) {
+	p.Lock()
+	if data.Editing && p.closeToKillTicker == nil && p.subs != nil {
+		p.lastSeen = p.lastSaved
+		p.closeToKillTicker = makeTicker(p.Debounce, p.checkEdits)
+	}
+	if !data.Editing && p.closeToKillTicker != nil {
+		close(p.closeToKillTicker)
+		p.closeToKillTicker = nil
+	}
+	p.Unlock()
+	if !data.Editing {
+		p.saveIfChanged()
+	}
+}
+
+// checkEdits saves the board if it has changed, but not since the last
+// check, Debounce ago.
+func (p *Persistence) checkEdits() {
+	serialised, err := p.serialise()
+	if err != nil {
+		return
+	}
+	p.Lock()
+	settled := serialised == p.lastSeen && serialised != p.lastSaved
+	p.lastSeen = serialised
+	p.Unlock()
+	if settled {
+		p.saveIfChanged()
+	}
+}
+
+// saveIfChanged saves the board if it differs from the saved board.
+func (p *Persistence) saveIfChanged() {
+	serialised, err := p.serialise()
+	if err != nil {
+		logError(p.key, err.Error())
+		return
+	}
+	p.Lock()
+	changed := serialised != p.lastSaved
+	p.Unlock()
+	if !changed {
+		return
+	}
+	if err := p.save(serialised); err != nil {
+		logError(p.key, err.Error())
+	}
+}
+
+// addRevertAction adds "Revert to Previous" to freeboard's toolbar,
+// which asks before calling RevertToPrevious. There's nothing to add to
+// if freeboard's page isn't there.
+func (p *Persistence) addRevertAction() {
+	if js.Global.Get("document") == js.Undefined {
+		return
+	}
+	doc := dom.GetWindow().Document()
+	toolbar := doc.QuerySelector("#board-actions .board-toolbar")
+	if toolbar == nil || doc.GetElementByID(revertActionID) != nil {
+		return
+	}
+	li := doc.CreateElement("li").(dom.HTMLElement)
+	li.SetID(revertActionID)
+	li.SetInnerHTML(`<i class="icon-repeat icon-white"></i><label>Revert to Previous</label>`)
+	li.AddEventListener("click", false, func(dom.Event) { go p.confirmRevert() })
+	toolbar.AppendChild(li)
+}
+
+// confirmRevert shows a dialog asking whether to revert to the latest
+// earlier version, and reverts if the user agrees.
+func (p *Persistence) confirmRevert() {
+	doc := dom.GetWindow().Document()
+	msg := doc.CreateElement("p").(dom.HTMLElement)
+	versions := p.SavedVersions()
+	if len(versions) == 0 {
+		msg.SetTextContent("There is no earlier version of this board.")
+		p.fb.OpenDialog(msg, "Revert to Previous", "OK", "", nil)
+		return
+	}
+	if savedAt := versions[len(versions)-1].SavedAt; savedAt.IsZero() {
+		msg.SetTextContent("Replace the board with the previous version?")
+	} else {
+		msg.SetTextContent("Replace the board with the version saved " + savedAt.Format("Jan 2 15:04:05") + "?")
+	}
+	p.fb.OpenDialog(msg, "Revert to Previous", "Revert", "Cancel", func() {
+		go func() {
+			if err := p.RevertToPrevious(nil); err != nil {
+				logError(p.key, err.Error())
+			}
+		}()
+	})
+}
+
+// serialise returns the current board as JSON.
+func (p *Persistence) serialise() (string, error) {
+	serialised, err := p.fb.call("serialize")
+	if err != nil {
+		return "", err
+	}
+	return js.Global.Get("JSON").Call("stringify", serialised).String(), nil
+}
+
+// Save saves the board now.
+func (p *Persistence) Save() error {
+	serialised, err := p.serialise()
+	if err != nil {
+		return err
+	}
+	return p.save(serialised)
+}
+
+// save stores serialised as the saved board, moving the board it
+// replaces into the versions with the time it was saved.
+func (p *Persistence) save(serialised string) error {
+	ls := localStorage()
+	if ls == nil {
+		return errors.New("freeboard: localStorage is unavailable")
+	}
+	p.Lock()
+	defer p.Unlock()
+	if previous, ok := p.current(ls); ok && string(previous.Data) != serialised {
+		versions := append(p.versions(ls), previous)
+		if len(versions) > p.Versions {
+			versions = versions[len(versions)-p.Versions:]
+		}
+		if err := p.storeVersions(ls, versions); err != nil {
+			return err
+		}
+	}
+	if err := p.storeCurrent(ls, PersistedVersion{SavedAt: time.Now(), Data: json.RawMessage(serialised)}); err != nil {
+		return err
+	}
+	p.lastSaved = serialised
+	return nil
+}
+
+// SavedVersions returns the earlier versions kept, oldest first.
+func (p *Persistence) SavedVersions() []PersistedVersion {
+	ls := localStorage()
+	if ls == nil {
+		return nil
+	}
+	p.Lock()
+	defer p.Unlock()
+	return p.versions(ls)
+}
+
+// RevertToPrevious replaces the board, and the saved board, with the
+// latest earlier version, and drops that version; callback, which may be
+// nil, is called once it's loaded. Repeated calls go further back.
+func (p *Persistence) RevertToPrevious(callback func()) error {
+	ls := localStorage()
+	if ls == nil {
+		return errors.New("freeboard: localStorage is unavailable")
+	}
+	p.Lock()
+	versions := p.versions(ls)
+	if len(versions) == 0 {
+		p.Unlock()
+		return errors.New("freeboard: there is no earlier version")
+	}
+	previous := versions[len(versions)-1]
+	err := p.storeVersions(ls, versions[:len(versions)-1])
+	if err == nil {
+		err = p.storeCurrent(ls, previous)
+	}
+	p.Unlock()
+	if err != nil {
+		return err
+	}
+	p.load(string(previous.Data), callback)
+	return nil
+}
+
+// Clear removes the saved board and its versions.
+func (p *Persistence) Clear() {
+	if ls := localStorage(); ls != nil {
+		ls.Call("removeItem", p.key)
+		ls.Call("removeItem", p.key+":versions")
+	}
+	p.Lock()
+	p.lastSaved = ""
+	p.Unlock()
+}
+
+// current reads the saved board, with the time it was saved, reporting
+// whether there is one. Boards saved before save times were kept are
+// stored bare, and read with a zero SavedAt. The caller must hold the
+// lock.
+func (p *Persistence) current(ls *js.Object) (PersistedVersion, bool) {
+	stored := ls.Call("getItem", p.key)
+	if stored == nil || stored == js.Undefined {
+		return PersistedVersion{}, false
+	}
+	var saved PersistedVersion
+	if err := json.Unmarshal([]byte(stored.String()), &saved); err != nil || len(saved.Data) == 0 {
+		return PersistedVersion{Data: json.RawMessage(stored.String())}, true
+	}
+	return saved, true
+}
+
+// storeCurrent writes the saved board. The caller must hold the lock.
+func (p *Persistence) storeCurrent(ls *js.Object, saved PersistedVersion) error {
+	data, err := marshalBoards(saved)
+	if err != nil {
+		return err
+	}
+	return setItem(ls, p.key, data)
+}
+
+// versions reads the stored versions. The caller must hold the lock.
+func (p *Persistence) versions(ls *js.Object) []PersistedVersion {
+	stored := ls.Call("getItem", p.key+":versions")
+	if stored == nil || stored == js.Undefined {
+		return nil
+	}
+	var versions []PersistedVersion
+	if err := json.Unmarshal([]byte(stored.String()), &versions); err != nil {
+		logError(p.key, "discarding unreadable versions: "+err.Error())
+		return nil
+	}
+	return versions
+}
+
+// storeVersions writes the versions. The caller must hold the lock.
+func (p *Persistence) storeVersions(ls *js.Object, versions []PersistedVersion) error {
+	data, err := marshalBoards(versions)
+	if err != nil {
+		return err
+	}
+	return setItem(ls, p.key+":versions", data)
+}
+
+// marshalBoards is json.Marshal without escaping HTML, so that the
+// boards in v read back just as freeboard serialised them, for
+// comparison with the live board.
+func marshalBoards(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// setItem stores a value in localStorage, returning an error rather than
+// panicking if it's full.
+func setItem(ls *js.Object, key, value string) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New("freeboard: can't save to localStorage: " + panicMessage(e))
+		}
+	}()
+	ls.Call("setItem", key, value)
+	return nil
+}
@@ -0,0 +1,74 @@
+//go:build js
+// +build js
+
+package freeboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// fakeLocalStorage puts a localStorage with just getItem, setItem and
+// removeItem on the global object, returning a func that removes it.
+func fakeLocalStorage() func() {
+	js.Global.Call("eval", `global.localStorage = {
+		items: {},
+		getItem: function(k) { return this.items.hasOwnProperty(k) ? this.items[k] : null; },
+		setItem: function(k, v) { this.items[k] = String(v); },
+		removeItem: function(k) { delete this.items[k]; }
+	}`)
+	return func() { js.Global.Delete("localStorage") }
+}
+
+func TestPersistedVersionsKeepTheirSaveTimes(t *testing.T) {
+	defer fakeLocalStorage()()
+	p := (&FBWrapper{}).Persist("versions_test")
+
+	first := `{"panes":[{"title":"<b>One</b>"}]}`
+	before := time.Now()
+	if err := p.save(first); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+	time.Sleep(20 * time.Millisecond)
+	if err := p.save(`{"panes":[]}`); err != nil {
+		t.Fatal(err)
+	}
+	versions := p.SavedVersions()
+	if len(versions) != 1 || string(versions[0].Data) != first {
+		t.Fatalf("versions are %+v", versions)
+	}
+	if savedAt := versions[0].SavedAt; savedAt.Before(before) || savedAt.After(after) {
+		t.Errorf("the version was saved between %v and %v, but says %v", before, after, savedAt)
+	}
+
+	// Saving the same board again doesn't make a version.
+	if err := p.save(`{"panes":[]}`); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(p.SavedVersions()); n != 1 {
+		t.Errorf("%d versions after saving the same board", n)
+	}
+}
+
+func TestPersistedBoardsSavedBare(t *testing.T) {
+	defer fakeLocalStorage()()
+	p := (&FBWrapper{}).Persist("bare_test")
+	bare := `{"version":1,"panes":[]}`
+	js.Global.Get("localStorage").Call("setItem", p.key, bare)
+
+	p.Lock()
+	saved, ok := p.current(js.Global.Get("localStorage"))
+	p.Unlock()
+	if !ok || string(saved.Data) != bare || !saved.SavedAt.IsZero() {
+		t.Errorf("a bare board reads as %+v, %v", saved, ok)
+	}
+	if err := p.save(`{"version":1,"panes":[{}]}`); err != nil {
+		t.Fatal(err)
+	}
+	if versions := p.SavedVersions(); len(versions) != 1 || string(versions[0].Data) != bare || !versions[0].SavedAt.IsZero() {
+		t.Errorf("versions are %+v", versions)
+	}
+}
@@ -0,0 +1,204 @@
+package freeboard
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// FreeboardDatasourceTypes are the TypeNames of the datasource plugins
+// that come with freeboard.
+var FreeboardDatasourceTypes = []string{"JSON", "openweathermap", "dweet_io", "playback", "clock", "meshblu"}
+
+// FreeboardWidgetTypes are the TypeNames of the widget plugins that come
+// with freeboard.
+var FreeboardWidgetTypes = []string{"text_widget", "gauge", "sparkline", "pointer", "picture", "indicator", "google_map", "html"}
+
+// pluginSchema is what a PluginSet knows of a plugin type. Plugins
+// written in JS are known only by name, so their settings can't be
+// checked, unless their definitions are added with AddDefinitions.
+type pluginSchema struct {
+	settings []FBSetting
+	// isGo is set if the settings are known.
+	isGo bool
+}
+
+// PluginSet is a set of known plugin types, against which dashboards are
+// built and checked. The zero value is not usable; use NewPluginSet.
+type PluginSet struct {
+	datasources map[string]pluginSchema
+	widgets     map[string]pluginSchema
+}
+
+// NewPluginSet returns a set that knows no plugin types.
+func NewPluginSet() *PluginSet {
+	return &PluginSet{
+		datasources: make(map[string]pluginSchema),
+		widgets:     make(map[string]pluginSchema),
+	}
+}
+
+// AddDatasources adds Go datasource plugins to the set.
+func (ps *PluginSet) AddDatasources(defs ...DsPluginDefinition) *PluginSet {
+	for _, def := range defs {
+		ps.datasources[def.TypeName] = pluginSchema{settings: def.Settings, isGo: true}
+	}
+	return ps
+}
+
+// AddWidgets adds Go widget plugins to the set.
+func (ps *PluginSet) AddWidgets(defs ...WtPluginDefinition) *PluginSet {
+	for _, def := range defs {
+		ps.widgets[def.TypeName] = pluginSchema{settings: def.Settings, isGo: true}
+	}
+	return ps
+}
+
+// AddJS adds plugins written in JS to the set, by TypeName. Types that
+// are known already are left as they are.
+func (ps *PluginSet) AddJS(datasourceTypes, widgetTypes []string) *PluginSet {
+	for _, t := range datasourceTypes {
+		if _, ok := ps.datasources[t]; !ok {
+			ps.datasources[t] = pluginSchema{}
+		}
+	}
+	for _, t := range widgetTypes {
+		if _, ok := ps.widgets[t]; !ok {
+			ps.widgets[t] = pluginSchema{}
+		}
+	}
+	return ps
+}
+
+// AddDefinitions adds plugins from JSON definitions, so that plugins
+// written in JS, or in Go outside this package, can be checked without
+// writing Go. The JSON holds the objects given to freeboard's
+// loadDatasourcePlugin and loadWidgetPlugin, less their functions:
+//
+//	{
+//		"datasources": [{"type_name": "weather", "settings": [
+//			{"name": "city", "type": "text", "required": true},
+//			{"name": "units", "type": "option", "options": [{"name": "Metric", "value": "metric"}]}
+//		]}],
+//		"widgets": [{"type_name": "dial", "settings": [{"name": "value", "type": "calculated"}]}]
+//	}
+//
+// Settings may have "display_name", "description" and "default_value";
+// array settings list their columns in "settings".
+func (ps *PluginSet) AddDefinitions(data []byte) error {
+	var defs struct {
+		Datasources []pluginDefinitionJSON `json:"datasources"`
+		Widgets     []pluginDefinitionJSON `json:"widgets"`
+	}
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return err
+	}
+	var dsDefs []DsPluginDefinition
+	for _, def := range defs.Datasources {
+		settings, err := def.toFBSettings()
+		if err != nil {
+			return err
+		}
+		dsDefs = append(dsDefs, DsPluginDefinition{TypeName: def.TypeName, DisplayName: def.DisplayName, Description: def.Description, Settings: settings})
+	}
+	var wtDefs []WtPluginDefinition
+	for _, def := range defs.Widgets {
+		settings, err := def.toFBSettings()
+		if err != nil {
+			return err
+		}
+		wtDefs = append(wtDefs, WtPluginDefinition{TypeName: def.TypeName, DisplayName: def.DisplayName, Description: def.Description, Settings: settings})
+	}
+	ps.AddDatasources(dsDefs...)
+	ps.AddWidgets(wtDefs...)
+	return nil
+}
+
+// pluginDefinitionJSON is a plugin definition read by AddDefinitions.
+type pluginDefinitionJSON struct {
+	TypeName    string `json:"type_name"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+	Settings    []struct {
+		Name         string      `json:"name"`
+		DisplayName  string      `json:"display_name"`
+		Description  string      `json:"description"`
+		Type         string      `json:"type"`
+		Required     bool        `json:"required"`
+		DefaultValue interface{} `json:"default_value"`
+		Options      []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"options"`
+		Settings []struct {
+			Name        string `json:"name"`
+			DisplayName string `json:"display_name"`
+			Type        string `json:"type"`
+		} `json:"settings"`
+	} `json:"settings"`
+}
+
+// toFBSettings converts the definition's settings.
+func (def pluginDefinitionJSON) toFBSettings() ([]FBSetting, error) {
+	if def.TypeName == "" {
+		return nil, errors.New("freeboard: plugin definition without a type_name")
+	}
+	where := "freeboard: plugin " + strconv.Quote(def.TypeName) + ": "
+	settings := make([]FBSetting, 0, len(def.Settings))
+	for _, s := range def.Settings {
+		set := FBSetting{
+			Name:        s.Name,
+			DisplayName: s.DisplayName,
+			Description: s.Description,
+			Type:        settingType(s.Type),
+			Required:    s.Required,
+		}
+		switch set.Type {
+		case SettingTextType, SettingNumberType, SettingCalculatedType, SettingBooleanType, SettingOptionType, SettingArrayType:
+		default:
+			return nil, errors.New(where + "setting " + strconv.Quote(s.Name) + " is of unknown type " + strconv.Quote(s.Type))
+		}
+		switch v := s.DefaultValue.(type) {
+		case string:
+			set.DefaultStringValue = v
+		case float64:
+			if v == math.Trunc(v) {
+				set.DefaultIntValue = int(v)
+			} else {
+				set.DefaultFloatValue = v
+			}
+		}
+		for _, opt := range s.Options {
+			set.Options = append(set.Options, FBSettingOpt{Name: opt.Name, Value: opt.Value})
+		}
+		for _, col := range s.Settings {
+			set.Settings = append(set.Settings, FBSettingSet{Name: col.Name, DisplayName: col.DisplayName, Type: settingType(col.Type)})
+		}
+		settings = append(settings, set)
+	}
+	return settings, nil
+}
+
+// AddFreeboard adds freeboard's own plugins to the set.
+func (ps *PluginSet) AddFreeboard() *PluginSet {
+	return ps.AddJS(FreeboardDatasourceTypes, FreeboardWidgetTypes)
+}
+
+// AddBuiltin adds the plugins defined in this package to the set.
+func (ps *PluginSet) AddBuiltin() *PluginSet {
+	ps.AddDatasources(MetricsDatasource)
+	return ps.AddWidgets(TextWidget, GaugeWidget, ChartWidget, TableWidget, IndicatorWidget, TemplateWidget)
+}
+
+// datasource looks up a datasource plugin type.
+func (ps *PluginSet) datasource(typeName string) (pluginSchema, bool) {
+	s, ok := ps.datasources[typeName]
+	return s, ok
+}
+
+// widget looks up a widget plugin type.
+func (ps *PluginSet) widget(typeName string) (pluginSchema, bool) {
+	s, ok := ps.widgets[typeName]
+	return s, ok
+}
@@ -1,5 +1,10 @@
 package freeboard
 
+import (
+	"errors"
+	"strconv"
+)
+
 type settingType string
 
 var (
@@ -47,6 +52,9 @@ type FBSetting struct {
 	Options []FBSettingOpt
 	// Settings is required for "array" type settings.
 	Settings []FBSettingSet
+	// Required settings must be given before freeboard's settings
+	// dialog will save.
+	Required bool
 	// DefaultValues are the default value. Optional. String takes precedence in text.
 	DefaultStringValue string
 	// DefaltIntValue or DefaultFloatValue can be used as default values for
@@ -64,26 +72,17 @@ func (set FBSetting) ToFBInterface() map[string]interface{} {
 	output["display_name"] = set.DisplayName
 	output["description"] = set.Description
 	output["type"] = string(set.Type)
+	if set.Required {
+		output["required"] = true
+	}
+	v, ok, err := set.defaultValue()
+	if err != nil {
+		panic(err.Error())
+	}
+	if ok {
+		output["default_value"] = v
+	}
 	switch set.Type {
-	case SettingTextType, SettingCalculatedType:
-		// Assuming that calculated type can have defaults?
-		{
-			if set.DefaultStringValue != "" {
-				output["default_value"] = set.DefaultStringValue
-			} else if set.DefaultIntValue != 0 {
-				output["default_value"] = set.DefaultIntValue
-			}
-		}
-	case SettingNumberType:
-		{
-			if set.DefaultIntValue != 0 && set.DefaultFloatValue == 0 {
-				output["default_value"] = set.DefaultIntValue
-			} else if set.DefaultFloatValue != 0.0 && set.DefaultIntValue == 0 {
-				output["default_value"] = set.DefaultFloatValue
-			} else if set.DefaultIntValue != 0 && set.DefaultFloatValue != 0.0 {
-				panic("Cannot have defaults for both int and float numeric values.")
-			}
-		}
 	case SettingOptionType:
 		{
 			output["options"] = make([]map[string]string, 0, len(set.Options))
@@ -109,9 +108,34 @@ func (set FBSetting) ToFBInterface() map[string]interface{} {
 				output["settings"] = append(output["settings"].([]map[string]string), s)
 			}
 		}
-		//case SettingBooleanType:  // No special handling required?
-	default:
-		panic("Unknown setting type: " + string(set.Type))
 	}
 	return output
 }
+
+// defaultValue returns the setting's default value, and whether it has
+// one. It is an error to give both int and float defaults for a number,
+// or for the setting to be of an unknown type.
+func (set FBSetting) defaultValue() (interface{}, bool, error) {
+	switch set.Type {
+	case SettingTextType, SettingCalculatedType:
+		// Assuming that calculated type can have defaults?
+		if set.DefaultStringValue != "" {
+			return set.DefaultStringValue, true, nil
+		} else if set.DefaultIntValue != 0 {
+			return set.DefaultIntValue, true, nil
+		}
+	case SettingNumberType:
+		if set.DefaultIntValue != 0 && set.DefaultFloatValue == 0 {
+			return set.DefaultIntValue, true, nil
+		} else if set.DefaultFloatValue != 0.0 && set.DefaultIntValue == 0 {
+			return set.DefaultFloatValue, true, nil
+		} else if set.DefaultIntValue != 0 && set.DefaultFloatValue != 0.0 {
+			return nil, false, errors.New("freeboard: setting " + strconv.Quote(set.Name) + " has both int and float defaults")
+		}
+	case SettingOptionType, SettingArrayType, SettingBooleanType:
+		// No defaults are read for these.
+	default:
+		return nil, false, errors.New("freeboard: setting " + strconv.Quote(set.Name) + " is of unknown type " + strconv.Quote(string(set.Type)))
+	}
+	return nil, false, nil
+}
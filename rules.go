@@ -0,0 +1,362 @@
+package freeboard
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Severity ranks the outcome of a Rule.
+type Severity int
+
+// Severities, from least to most severe.
+const (
+	SeverityOK Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = []string{"ok", "info", "warning", "critical"}
+
+// String returns the severity's name: ok, info, warning or critical.
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return "unknown"
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity reads a severity name, as returned by Severity.String.
+func ParseSeverity(name string) (Severity, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return SeverityOK, errors.New("freeboard: unknown severity " + strconv.Quote(name))
+}
+
+// RuleKind is the kind of test a Rule makes.
+type RuleKind string
+
+// Rule kinds.
+const (
+	// RuleCompare compares the value, e.g. "> 90" or "== down".
+	RuleCompare RuleKind = "compare"
+	// RuleRange matches values from Min up to but not including Max,
+	// written "10..20"; either end may be left open, as in "..20".
+	RuleRange RuleKind = "range"
+	// RuleRate compares the value's rate of change per second, e.g. "> 5".
+	RuleRate RuleKind = "rate"
+	// RuleNoData matches when no value has arrived for the given number
+	// of seconds, e.g. "30".
+	RuleNoData RuleKind = "nodata"
+	// RuleAny always matches; its condition is ignored. It serves as
+	// an "else" among rules of the same severity.
+	RuleAny RuleKind = "any"
+)
+
+// Rule is one test on a calculated value, and what it means if it matches.
+type Rule struct {
+	Kind RuleKind
+	// Key, if set, is a dotted path such as host.name into an object
+	// value; RuleCompare and RuleRange test the value found there
+	// instead of the whole value.
+	Key string
+	// Op and Operand are the comparison for RuleCompare and RuleRate;
+	// see Compare.
+	Op, Operand string
+	// Min and Max bound RuleRange; use infinities for open ends.
+	Min, Max float64
+	// Seconds is the silence that triggers RuleNoData.
+	Seconds float64
+
+	Severity Severity
+	// Class is a CSS class for widgets to apply. If it is empty,
+	// "gfb-severity-" and the severity's name is used.
+	Class string
+	// Message is optional text; "{value}" in it is replaced by the value.
+	Message string
+}
+
+// ParseRule builds a rule from a kind, a condition written as described
+// for the RuleKind constants, a severity name, a class and a message. A
+// blank kind is RuleCompare, and a blank severity is SeverityWarning.
+func ParseRule(kind, condition, severity, class, message string) (Rule, error) {
+	r := Rule{Kind: RuleKind(strings.ToLower(strings.TrimSpace(kind))), Class: class, Message: message}
+	if r.Kind == "" {
+		r.Kind = RuleCompare
+	}
+	r.Severity = SeverityWarning
+	var err error
+	if strings.TrimSpace(severity) != "" {
+		if r.Severity, err = ParseSeverity(severity); err != nil {
+			return r, err
+		}
+	}
+	condition = strings.TrimSpace(condition)
+	switch r.Kind {
+	case RuleCompare, RuleRate:
+		r.Op, r.Operand = splitComparison(condition)
+	case RuleRange:
+		parts := strings.SplitN(condition, "..", 2)
+		if len(parts) != 2 {
+			return r, errors.New("freeboard: range must be written min..max, not " + strconv.Quote(condition))
+		}
+		r.Min, r.Max = math.Inf(-1), math.Inf(1)
+		if s := strings.TrimSpace(parts[0]); s != "" {
+			if r.Min, err = strconv.ParseFloat(s, 64); err != nil {
+				return r, err
+			}
+		}
+		if s := strings.TrimSpace(parts[1]); s != "" {
+			if r.Max, err = strconv.ParseFloat(s, 64); err != nil {
+				return r, err
+			}
+		}
+	case RuleNoData:
+		if r.Seconds, err = strconv.ParseFloat(condition, 64); err != nil {
+			return r, err
+		}
+	case RuleAny:
+	default:
+		return r, errors.New("freeboard: unknown rule kind " + strconv.Quote(kind))
+	}
+	return r, nil
+}
+
+// comparisonOps are the operators understood by Compare, longest first
+// so that ">=" isn't read as ">".
+var comparisonOps = []string{">=", "<=", "==", "!=", ">", "<", "=", "contains"}
+
+// CompareRule builds a RuleCompare rule from an operator and operand
+// given separately, as in widgets whose settings have a column for each,
+// testing the value at key. A blank op makes a RuleAny rule, as Compare
+// treats a blank op as always matching. Unknown operators are an error.
+func CompareRule(key, op, operand string, severity Severity) (Rule, error) {
+	op = strings.TrimSpace(op)
+	if op == "" {
+		return Rule{Kind: RuleAny, Severity: severity}, nil
+	}
+	for _, known := range comparisonOps {
+		if op == known {
+			return Rule{Kind: RuleCompare, Key: key, Op: op, Operand: strings.TrimSpace(operand), Severity: severity}, nil
+		}
+	}
+	return Rule{}, errors.New("freeboard: unknown operator " + strconv.Quote(op))
+}
+
+// splitComparison splits "> 90" into ">" and "90". A condition with no
+// operator is taken as equality.
+func splitComparison(condition string) (op, operand string) {
+	for _, candidate := range comparisonOps {
+		if strings.HasPrefix(condition, candidate) {
+			return candidate, strings.TrimSpace(condition[len(candidate):])
+		}
+	}
+	if condition == "" {
+		return "", ""
+	}
+	return "==", condition
+}
+
+// RuleResult is the outcome of evaluating a RuleSet.
+type RuleResult struct {
+	Severity Severity
+	Class    string
+	Message  string
+	// Rule is the index of the rule that matched, or -1 if none did.
+	Rule int
+}
+
+// RuleSet evaluates rules against the values of one calculated setting.
+// Feed it values with Observe, and read the outcome with Evaluate, or
+// have it call back with Watch when the outcome changes. The zero value
+// has no rules, and always evaluates as SeverityOK.
+type RuleSet struct {
+	sync.Mutex
+	Rules []Rule
+
+	value, prevValue float64
+	numeric          bool
+	last             interface{}
+	lastAt, prevAt   time.Time
+	// since is when the RuleSet was made, from which RuleNoData counts
+	// until the first value arrives.
+	since time.Time
+}
+
+// NewRuleSet returns a RuleSet with the given rules.
+func NewRuleSet(rules []Rule) *RuleSet {
+	return &RuleSet{Rules: rules, since: time.Now()}
+}
+
+// SetRules replaces the rules, keeping the values observed so far.
+func (rs *RuleSet) SetRules(rules []Rule) {
+	rs.Lock()
+	rs.Rules = rules
+	rs.Unlock()
+}
+
+// Observe records a new value, arriving now.
+func (rs *RuleSet) Observe(v interface{}) {
+	rs.Lock()
+	defer rs.Unlock()
+	now := time.Now()
+	n, ok := toFloat(v)
+	rs.prevValue, rs.prevAt = rs.value, rs.lastAt
+	if !rs.numeric || !ok {
+		// A rate needs two numbers in a row.
+		rs.prevAt = time.Time{}
+	}
+	rs.value, rs.numeric, rs.last, rs.lastAt = n, ok, v, now
+}
+
+// Evaluate tests every rule and returns the most severe match, taking the
+// first among equals. If nothing matches, the result is SeverityOK with
+// Rule set to -1.
+func (rs *RuleSet) Evaluate() RuleResult {
+	rs.Lock()
+	defer rs.Unlock()
+	best := RuleResult{Severity: SeverityOK, Class: "gfb-severity-ok", Rule: -1}
+	now := time.Now()
+	for i, r := range rs.Rules {
+		if !rs.matches(r, now) {
+			continue
+		}
+		if best.Rule >= 0 && r.Severity <= best.Severity {
+			continue
+		}
+		best = RuleResult{
+			Severity: r.Severity,
+			Class:    r.Class,
+			Message:  strings.Replace(r.Message, "{value}", displayValue(rs.last), -1),
+			Rule:     i,
+		}
+		if best.Class == "" {
+			best.Class = "gfb-severity-" + r.Severity.String()
+		}
+	}
+	return best
+}
+
+// matches tests one rule. The caller must hold the lock.
+func (rs *RuleSet) matches(r Rule, now time.Time) bool {
+	switch r.Kind {
+	case RuleAny:
+		return true
+	case RuleNoData:
+		from := rs.lastAt
+		if from.IsZero() {
+			from = rs.since
+		}
+		return from.IsZero() || now.Sub(from).Seconds() >= r.Seconds
+	case RuleCompare:
+		return !rs.lastAt.IsZero() && r.Op != "" && Compare(lookupPath(rs.last, r.Key), r.Op, r.Operand)
+	case RuleRange:
+		if r.Key != "" {
+			v, ok := toFloat(lookupPath(rs.last, r.Key))
+			return ok && v >= r.Min && v < r.Max
+		}
+		return rs.numeric && rs.value >= r.Min && rs.value < r.Max
+	case RuleRate:
+		if !rs.numeric || rs.prevAt.IsZero() {
+			return false
+		}
+		dt := rs.lastAt.Sub(rs.prevAt).Seconds()
+		if dt <= 0 {
+			return false
+		}
+		return Compare((rs.value-rs.prevValue)/dt, r.Op, r.Operand)
+	}
+	return false
+}
+
+// Watch calls fn with the result of Evaluate straight away, and then
+// checks every second, calling fn again whenever the result differs from
+// the last. This catches RuleNoData rules, which can start matching with
+// no new value. Close the returned channel to stop watching.
+func (rs *RuleSet) Watch(fn func(RuleResult)) chan interface{} {
+	var mu sync.Mutex
+	last := RuleResult{Rule: -2}
+	check := func() {
+		res := rs.Evaluate()
+		mu.Lock()
+		changed := res != last
+		last = res
+		mu.Unlock()
+		if changed {
+			fn(res)
+		}
+	}
+	check()
+	return makeTicker(time.Second, check)
+}
+
+// evaluateRules evaluates rules once against a single value.
+func evaluateRules(rules []Rule, v interface{}) RuleResult {
+	rs := NewRuleSet(rules)
+	rs.Observe(v)
+	return rs.Evaluate()
+}
+
+// RulesSetting is a standard array-type setting for editing rules in a
+// widget's dialog. Add it to a widget's Settings, possibly renamed, and
+// read it with RulesFromSettings.
+var RulesSetting = FBSetting{
+	Name:        "alert_rules",
+	DisplayName: "Alert Rules",
+	Description: "Kind is compare (e.g. > 90), range (e.g. 10..20), rate (change per second, e.g. > 5), nodata (seconds, e.g. 30) or any. " +
+		"Severity is ok, info, warning or critical; the most severe match wins. Message may include {value}.",
+	Type: SettingArrayType,
+	Settings: []FBSettingSet{
+		FBSettingSet{Name: "kind", DisplayName: "Kind", Type: SettingTextType},
+		FBSettingSet{Name: "condition", DisplayName: "Condition", Type: SettingTextType},
+		FBSettingSet{Name: "severity", DisplayName: "Severity", Type: SettingTextType},
+		FBSettingSet{Name: "class", DisplayName: "CSS Class", Type: SettingTextType},
+		FBSettingSet{Name: "message", DisplayName: "Message", Type: SettingTextType},
+	},
+}
+
+// RulesFromSettings reads rules from the named setting, laid out as
+// RulesSetting. Rows that don't parse are skipped, and their errors
+// returned alongside the rules that do.
+func RulesFromSettings(settings *js.Object, name string) ([]Rule, []error) {
+	var rows []map[string]string
+	for _, row := range settingRows(settings, name) {
+		fields := make(map[string]string, len(RulesSetting.Settings))
+		for _, f := range RulesSetting.Settings {
+			fields[f.Name] = settingString(row, f.Name)
+		}
+		rows = append(rows, fields)
+	}
+	return parseRuleRows(rows)
+}
+
+// parseRuleRows parses rows of RulesSetting's fields. Errors name the
+// row they were found in, counting from 1.
+func parseRuleRows(rows []map[string]string) ([]Rule, []error) {
+	var rules []Rule
+	var errs []error
+	for i, row := range rows {
+		r, err := ParseRule(row["kind"], row["condition"], row["severity"], row["class"], row["message"])
+		if err != nil {
+			errs = append(errs, rowError(i, err))
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules, errs
+}
+
+// rowError prefixes err with the number, counting from 1, of row i.
+func rowError(i int, err error) error {
+	return errors.New("row " + strconv.Itoa(i+1) + ": " + err.Error())
+}
@@ -0,0 +1,195 @@
+package freeboard
+
+import (
+	"testing"
+	"time"
+)
+
+// rule parses a rule for a test, failing it if the rule doesn't parse.
+func rule(t *testing.T, kind, condition, severity, message string) Rule {
+	r, err := ParseRule(kind, condition, severity, "", message)
+	if err != nil {
+		t.Fatalf("ParseRule(%q, %q, %q): %v", kind, condition, severity, err)
+	}
+	return r
+}
+
+func TestRuleOperators(t *testing.T) {
+	for _, tc := range []struct {
+		kind, condition string
+		value           interface{}
+		want            bool
+	}{
+		{"", "> 90", 91.0, true},
+		{"", "> 90", 90.0, false},
+		{"", ">= 90", 90.0, true},
+		{"", "< 10", "9.5", true},
+		{"", "<= 10", 11, false},
+		{"compare", "== down", "down", true},
+		{"compare", "down", "down", true},
+		{"compare", "!= down", "up", true},
+		{"compare", "= 3", 3.0, true},
+		{"compare", "contains err", "an error", true},
+		{"compare", "contains err", "fine", false},
+		{"range", "10..20", 10.0, true},
+		{"range", "10..20", 20.0, false},
+		{"range", "..20", -1e9, true},
+		{"range", "10..", "15", true},
+		{"range", "10..20", "ten", false},
+		{"any", "", "anything", true},
+		{"any", "ignored", nil, true},
+	} {
+		rs := NewRuleSet([]Rule{rule(t, tc.kind, tc.condition, "critical", "")})
+		rs.Observe(tc.value)
+		if got := rs.Evaluate().Rule == 0; got != tc.want {
+			t.Errorf("%s %q on %#v: matched %v, want %v", tc.kind, tc.condition, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestRuleCompareWaitsForAValue(t *testing.T) {
+	rs := NewRuleSet([]Rule{rule(t, "", "== ", "warning", "")})
+	if res := rs.Evaluate(); res.Rule != -1 {
+		t.Errorf("a compare rule matched before any value: %+v", res)
+	}
+}
+
+func TestRuleOrdering(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		rules    []Rule
+		value    interface{}
+		want     int
+		severity Severity
+	}{
+		{
+			"most severe wins",
+			[]Rule{rule(t, "", "> 50", "warning", ""), rule(t, "", "> 90", "critical", "")},
+			95.0, 1, SeverityCritical,
+		},
+		{
+			"first among equals",
+			[]Rule{rule(t, "", "> 50", "warning", ""), rule(t, "", "> 90", "warning", "")},
+			95.0, 0, SeverityWarning,
+		},
+		{
+			"any as an else",
+			[]Rule{rule(t, "", "> 90", "warning", ""), rule(t, "any", "", "warning", "")},
+			10.0, 1, SeverityWarning,
+		},
+		{
+			"nothing matches",
+			[]Rule{rule(t, "", "> 90", "critical", "")},
+			10.0, -1, SeverityOK,
+		},
+	} {
+		res := evaluateRules(tc.rules, tc.value)
+		if res.Rule != tc.want || res.Severity != tc.severity {
+			t.Errorf("%s: got rule %d at %v, want rule %d at %v", tc.name, res.Rule, res.Severity, tc.want, tc.severity)
+		}
+	}
+}
+
+func TestRuleResultClassAndMessage(t *testing.T) {
+	r := rule(t, "", "> 90", "critical", "{value} is too hot")
+	res := evaluateRules([]Rule{r}, 95.5)
+	if res.Class != "gfb-severity-critical" || res.Message != "95.5 is too hot" {
+		t.Errorf("got class %q and message %q", res.Class, res.Message)
+	}
+	r.Class = "hot"
+	if res := evaluateRules([]Rule{r}, 95.5); res.Class != "hot" {
+		t.Errorf("got class %q, want the rule's own", res.Class)
+	}
+}
+
+func TestRuleKeys(t *testing.T) {
+	row := map[string]interface{}{"host": map[string]interface{}{"load": 3.0, "name": "db1"}}
+	for _, tc := range []struct {
+		rule Rule
+		want bool
+	}{
+		{Rule{Kind: RuleCompare, Key: "host.name", Op: "==", Operand: "db1"}, true},
+		{Rule{Kind: RuleCompare, Key: "host.load", Op: ">", Operand: "2"}, true},
+		{Rule{Kind: RuleCompare, Key: "host.missing", Op: ">", Operand: "2"}, false},
+		{Rule{Kind: RuleRange, Key: "host.load", Min: 0, Max: 5}, true},
+		{Rule{Kind: RuleRange, Key: "host.name", Min: 0, Max: 5}, false},
+	} {
+		tc.rule.Severity = SeverityWarning
+		if got := evaluateRules([]Rule{tc.rule}, row).Rule == 0; got != tc.want {
+			t.Errorf("%+v: matched %v, want %v", tc.rule, got, tc.want)
+		}
+	}
+}
+
+func TestRuleRate(t *testing.T) {
+	rs := NewRuleSet([]Rule{rule(t, "rate", "> 5", "warning", "")})
+	rs.Observe(0.0)
+	time.Sleep(20 * time.Millisecond)
+	rs.Observe(10.0)
+	if res := rs.Evaluate(); res.Rule != 0 {
+		t.Errorf("a rise of 10 in 20ms didn't match a rate over 5 a second")
+	}
+	rs.Observe("n/a")
+	if res := rs.Evaluate(); res.Rule != -1 {
+		t.Errorf("a rate matched on a value that isn't a number")
+	}
+}
+
+func TestRuleNoData(t *testing.T) {
+	rs := NewRuleSet([]Rule{rule(t, "nodata", "0.01", "critical", "")})
+	if res := rs.Evaluate(); res.Rule != -1 {
+		t.Errorf("nodata matched straight away")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if res := rs.Evaluate(); res.Rule != 0 {
+		t.Errorf("nodata didn't match after a silence")
+	}
+	rs.Observe(1.0)
+	if res := rs.Evaluate(); res.Rule != -1 {
+		t.Errorf("nodata matched on a fresh value")
+	}
+}
+
+func TestParseRuleRowsBadRows(t *testing.T) {
+	rows := []map[string]string{
+		{"kind": "compare", "condition": "> 1"},
+		{"kind": "sometimes", "condition": "> 1"},
+		{"kind": "range", "condition": "10-20"},
+		{"kind": "range", "condition": "a..b"},
+		{"kind": "nodata", "condition": "soon"},
+		{"condition": "> 1", "severity": "dire"},
+		{"kind": "any", "severity": "info"},
+	}
+	rules, errs := parseRuleRows(rows)
+	if len(rules) != 2 || rules[0].Kind != RuleCompare || rules[1].Kind != RuleAny {
+		t.Errorf("got rules %+v, want the first and last rows", rules)
+	}
+	want := []string{"row 2: ", "row 3: ", "row 4: ", "row 5: ", "row 6: "}
+	if len(errs) != len(want) {
+		t.Fatalf("got errors %v, want %d", errs, len(want))
+	}
+	for i, err := range errs {
+		if msg := err.Error(); len(msg) < len(want[i]) || msg[:len(want[i])] != want[i] {
+			t.Errorf("error %d is %q, want it to start %q", i, msg, want[i])
+		}
+	}
+}
+
+func TestCompareRule(t *testing.T) {
+	for _, tc := range []struct {
+		op   string
+		kind RuleKind
+		ok   bool
+	}{
+		{">", RuleCompare, true},
+		{" contains ", RuleCompare, true},
+		{"", RuleAny, true},
+		{"gt", "", false},
+		{"=>", "", false},
+	} {
+		r, err := CompareRule("key", tc.op, "1", SeverityInfo)
+		if (err == nil) != tc.ok || (tc.ok && r.Kind != tc.kind) {
+			t.Errorf("CompareRule(%q): got %+v, %v", tc.op, r, err)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Package server stores freeboard dashboards as JSON files in a
+// directory, and serves them over a small REST API:
+//
+//	GET    /            list dashboards, as a JSON array of freeboard.DashboardInfo
+//	GET    /{name}      get a dashboard
+//	POST   /{name}      create a dashboard; 409 Conflict if it exists
+//	PUT    /{name}      update a dashboard; requires If-Match
+//	DELETE /{name}      delete a dashboard; If-Match is optional
+//
+// Every dashboard has an ETag, which changes whenever it does. Updates
+// must send the ETag they were based on as If-Match, and fail with 412
+// Precondition Failed if someone else has saved since, so that edits
+// aren't silently lost. GET honours If-None-Match.
+//
+// Names are 1 to 64 letters, digits, dashes and underscores. Bodies
+// must be dashboards, as freeboard.ParseDashboard reads them, with
+// "datasources" and "panes" lists, even if empty.
+//
+// A Server handles paths relative to where it's mounted, e.g.
+//
+//	http.Handle("/dashboards/", http.StripPrefix("/dashboards", srv))
+//
+// freeboard.DashboardClient talks to it from the browser.
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	freeboard "github.com/cathalgarvey/go-freeboard"
+)
+
+// MaxDashboardSize is the largest dashboard accepted, in bytes.
+const MaxDashboardSize = 4 << 20
+
+// validName matches dashboard names.
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Server is an http.Handler storing dashboards in a directory.
+type Server struct {
+	sync.Mutex
+	dir string
+}
+
+// New returns a Server storing dashboards in dir, which is created if
+// it doesn't exist.
+func New(dir string) (*Server, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Server{dir: dir}, nil
+}
+
+// ServeHTTP satisfies the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(r.URL.Path, "/")
+	if name == "" {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.list(w)
+		return
+	}
+	if !validName.MatchString(name) {
+		http.Error(w, "invalid dashboard name", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET", "HEAD":
+		s.get(w, r, name)
+	case "POST":
+		s.put(w, r, name, true)
+	case "PUT":
+		s.put(w, r, name, false)
+	case "DELETE":
+		s.delete(w, r, name)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// path returns the file a dashboard is stored in.
+func (s *Server) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// etag is the ETag of a dashboard's content.
+func etag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matches reports whether an If-Match or If-None-Match header matches
+// the ETag current, which is "" if there is no dashboard.
+func matches(header, current string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if (tag == "*" && current != "") || (tag != "" && tag == current) {
+			return true
+		}
+	}
+	return false
+}
+
+// read returns a dashboard's content and ETag, or nil and "" if there is
+// no such dashboard. The caller must hold the lock.
+func (s *Server) read(name string) ([]byte, string, error) {
+	data, err := ioutil.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, etag(data), nil
+}
+
+func (s *Server) list(w http.ResponseWriter) {
+	s.Lock()
+	files, err := ioutil.ReadDir(s.dir)
+	infos := make([]freeboard.DashboardInfo, 0, len(files))
+	for _, fi := range files {
+		name := strings.TrimSuffix(fi.Name(), ".json")
+		if fi.IsDir() || name == fi.Name() || !validName.MatchString(name) {
+			continue
+		}
+		data, tag, rerr := s.read(name)
+		if rerr != nil || data == nil {
+			continue
+		}
+		infos = append(infos, freeboard.DashboardInfo{Name: name, ETag: tag, Modified: fi.ModTime()})
+	}
+	s.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Sort(byName(infos))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, name string) {
+	s.Lock()
+	data, tag, err := s.read(name)
+	s.Unlock()
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case data == nil:
+		http.Error(w, "no such dashboard", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("ETag", tag)
+	if matches(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// put creates or updates a dashboard.
+func (s *Server) put(w http.ResponseWriter, r *http.Request, name string, create bool) {
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxDashboardSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := checkDashboard(data); err != nil {
+		http.Error(w, "not a dashboard: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if !create && ifMatch == "" {
+		http.Error(w, "updates need an If-Match header", http.StatusPreconditionRequired)
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	_, current, err := s.read(name)
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case create && current != "":
+		w.Header().Set("ETag", current)
+		http.Error(w, "dashboard exists", http.StatusConflict)
+		return
+	case !create && current == "":
+		http.Error(w, "no such dashboard", http.StatusNotFound)
+		return
+	case !create && !matches(ifMatch, current):
+		w.Header().Set("ETag", current)
+		http.Error(w, "dashboard has changed", http.StatusPreconditionFailed)
+		return
+	}
+	if err := writeFile(s.path(name), data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(data))
+	if create {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, name string) {
+	s.Lock()
+	defer s.Unlock()
+	_, current, err := s.read(name)
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case current == "":
+		http.Error(w, "no such dashboard", http.StatusNotFound)
+		return
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !matches(ifMatch, current) {
+		w.Header().Set("ETag", current)
+		http.Error(w, "dashboard has changed", http.StatusPreconditionFailed)
+		return
+	}
+	if err := os.Remove(s.path(name)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkDashboard returns an error if data isn't a dashboard. Any JSON
+// object parses as one, so the lists every board has must be there.
+func checkDashboard(data []byte) error {
+	if _, err := freeboard.ParseDashboard(data); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, key := range []string{"datasources", "panes"} {
+		if _, ok := fields[key]; !ok {
+			return errors.New("no " + strconv.Quote(key))
+		}
+	}
+	return nil
+}
+
+// writeFile writes data to path by way of a temporary file, so that a
+// failed write doesn't leave half a dashboard.
+func writeFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	// TempFile makes files only the owner can read.
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+type byName []freeboard.DashboardInfo
+
+func (bn byName) Len() int           { return len(bn) }
+func (bn byName) Swap(i, j int)      { bn[i], bn[j] = bn[j], bn[i] }
+func (bn byName) Less(i, j int) bool { return bn[i].Name < bn[j].Name }
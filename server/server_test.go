@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	freeboard "github.com/cathalgarvey/go-freeboard"
+)
+
+const board = `{"version": 1, "allow_edit": true, "plugins": [], "panes": [], "datasources": [], "columns": 3}`
+
+// newTestServer starts a Server in a temporary directory.
+func newTestServer(t *testing.T) (*httptest.Server, string, func()) {
+	dir, err := ioutil.TempDir("", "fbserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	return ts, dir, func() {
+		ts.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// do makes a request, returning the response's status and ETag.
+func do(t *testing.T, ts *httptest.Server, method, path, body, ifMatch string) (int, string) {
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("ETag")
+}
+
+func TestServerLifecycle(t *testing.T) {
+	ts, dir, done := newTestServer(t)
+	defer done()
+
+	status, tag := do(t, ts, "POST", "/ops", board, "")
+	if status != http.StatusCreated || tag == "" {
+		t.Fatalf("create: %d, ETag %q", status, tag)
+	}
+	if status, _ := do(t, ts, "POST", "/ops", board, ""); status != http.StatusConflict {
+		t.Errorf("duplicate create: %d, want 409", status)
+	}
+	if status, got := do(t, ts, "GET", "/ops", "", ""); status != http.StatusOK || got != tag {
+		t.Errorf("get: %d, ETag %q, want 200 and %q", status, got, tag)
+	}
+
+	changed := strings.Replace(board, `"columns": 3`, `"columns": 4`, 1)
+	if status, _ := do(t, ts, "PUT", "/ops", changed, ""); status != http.StatusPreconditionRequired {
+		t.Errorf("update without If-Match: %d, want 428", status)
+	}
+	status, newTag := do(t, ts, "PUT", "/ops", changed, tag)
+	if status != http.StatusNoContent || newTag == tag {
+		t.Fatalf("update: %d, ETag %q", status, newTag)
+	}
+	if status, _ := do(t, ts, "PUT", "/ops", board, tag); status != http.StatusPreconditionFailed {
+		t.Errorf("update on a stale ETag: %d, want 412", status)
+	}
+	if status, _ := do(t, ts, "PUT", "/missing", board, tag); status != http.StatusNotFound {
+		t.Errorf("update of a missing board: %d, want 404", status)
+	}
+
+	fi, err := os.Stat(filepath.Join(dir, "ops.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0644 {
+		t.Errorf("saved with mode %v, want 0644", fi.Mode().Perm())
+	}
+
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var infos []freeboard.DashboardInfo
+	err = json.NewDecoder(resp.Body).Decode(&infos)
+	resp.Body.Close()
+	if err != nil || len(infos) != 1 || infos[0].Name != "ops" || infos[0].ETag != newTag {
+		t.Errorf("list: %+v, %v", infos, err)
+	}
+
+	if status, _ := do(t, ts, "DELETE", "/ops", "", tag); status != http.StatusPreconditionFailed {
+		t.Errorf("delete on a stale ETag: %d, want 412", status)
+	}
+	if status, _ := do(t, ts, "DELETE", "/ops", "", newTag); status != http.StatusNoContent {
+		t.Errorf("delete: %d, want 204", status)
+	}
+	if status, _ := do(t, ts, "GET", "/ops", "", ""); status != http.StatusNotFound {
+		t.Errorf("get after delete: %d, want 404", status)
+	}
+	if status, _ := do(t, ts, "DELETE", "/ops", "", ""); status != http.StatusNotFound {
+		t.Errorf("second delete: %d, want 404", status)
+	}
+}
+
+func TestServerRejectsBadBodies(t *testing.T) {
+	ts, _, done := newTestServer(t)
+	defer done()
+	for _, body := range []string{
+		``,
+		`not json`,
+		`[]`,
+		`{"version": 1}`,
+		`{"version": 1, "panes": []}`,
+		`{"version": 1, "datasources": []}`,
+		`{"version": 1, "panes": {}, "datasources": []}`,
+	} {
+		if status, _ := do(t, ts, "POST", "/bad", body, ""); status != http.StatusBadRequest {
+			t.Errorf("create with %q: %d, want 400", body, status)
+		}
+	}
+}
+
+func TestServerNames(t *testing.T) {
+	ts, _, done := newTestServer(t)
+	defer done()
+	for _, tc := range []struct {
+		name   string
+		status int
+	}{
+		{"ok-name_1", http.StatusCreated},
+		{"has.dot", http.StatusNotFound},
+		{"has%20space", http.StatusNotFound},
+		{"a/b", http.StatusNotFound},
+		{"..%2Fescape", http.StatusNotFound},
+		{strings.Repeat("x", 65), http.StatusNotFound},
+	} {
+		if status, _ := do(t, ts, "POST", "/"+tc.name, board, ""); status != tc.status {
+			t.Errorf("create %q: %d, want %d", tc.name, status, tc.status)
+		}
+	}
+	if status, _ := do(t, ts, "POST", "/", board, ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("POST to the list: %d, want 405", status)
+	}
+}
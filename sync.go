@@ -0,0 +1,157 @@
+package freeboard
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// SyncTimeout is how long the blocking variants of FBWrapper's methods
+// wait for freeboard before giving up with ErrTimeout.
+var SyncTimeout = 30 * time.Second
+
+// ErrTimeout is returned when freeboard doesn't finish within SyncTimeout.
+// Freeboard doesn't report every failure, so a timeout may well mean that
+// loading failed: a plugin's script may have hung, or a plugin may have
+// thrown an exception that freeboard didn't catch.
+var ErrTimeout = errors.New("freeboard: timed out waiting for freeboard")
+
+// InitializeSync is Initialize, but blocks until freeboard has finished,
+// restoring the saved board if Persist has been called. Like the other
+// blocking methods, call it from a goroutine, such as main, rather than
+// straight from a JS callback.
+func (fb *FBWrapper) InitializeSync(allowEdit bool) error {
+	return waitForFreeboard(func(done func()) error {
+		return fb.InitializeBoard(allowEdit, done)
+	})
+}
+
+// LoadDashboardSync is LoadGoDashboard, but blocks until the board has
+// loaded.
+func (fb *FBWrapper) LoadDashboardSync(d *Dashboard) error {
+	return waitForFreeboard(func(done func()) error {
+		return fb.LoadGoDashboard(d, done)
+	})
+}
+
+// waitForFreeboard calls start with a func for freeboard to call when
+// it's done, and waits for that. A JS exception thrown by start is
+// returned as an error, as is any error start returns. While it waits,
+// a script or stylesheet that fails to load is returned as an error
+// straight away, as freeboard will never finish; an uncaught exception
+// is returned instead of ErrTimeout if it then times out.
+func waitForFreeboard(start func(done func()) error) error {
+	finished := make(chan struct{})
+	var once sync.Once
+	done := func() { once.Do(func() { close(finished) }) }
+	failed := make(chan error, 1)
+	var mu sync.Mutex
+	var thrown error
+	stop := watchPageErrors(func(err error, fatal bool) {
+		if fatal {
+			select {
+			case failed <- err:
+			default:
+			}
+			return
+		}
+		mu.Lock()
+		thrown = err
+		mu.Unlock()
+	})
+	defer stop()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = errors.New("freeboard: " + panicMessage(r))
+			}
+		}()
+		return start(done)
+	}()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-finished:
+		return nil
+	case err := <-failed:
+		return err
+	case <-time.After(SyncTimeout):
+		mu.Lock()
+		defer mu.Unlock()
+		if thrown != nil {
+			return thrown
+		}
+		return ErrTimeout
+	}
+}
+
+// watchPageErrors calls report with the errors the page reports until
+// the returned func is called. Failures to load scripts and stylesheets
+// are fatal; uncaught exceptions aren't. Outside a browser there are no
+// errors to watch.
+func watchPageErrors(report func(err error, fatal bool)) func() {
+	window := js.Global.Get("window")
+	if window == nil || window == js.Undefined || window.Get("addEventListener") == js.Undefined {
+		return func() {}
+	}
+	// Load failures don't bubble, so they're caught on the way down.
+	listener := js.MakeFunc(func(this *js.Object, args []*js.Object) interface{} {
+		if len(args) > 0 {
+			if err, fatal := pageError(args[0]); err != nil {
+				report(err, fatal)
+			}
+		}
+		return nil
+	})
+	window.Call("addEventListener", "error", listener, true)
+	return func() { window.Call("removeEventListener", "error", listener, true) }
+}
+
+// pageError describes an error event: a failure to load a script or
+// stylesheet, whose target is the element, which is fatal, or an
+// uncaught exception, which has a message. Other elements failing to
+// load, such as a widget's broken image, aren't freeboard's failures,
+// and are ignored.
+func pageError(event *js.Object) (err error, fatal bool) {
+	if target := event.Get("target"); target != nil && target != js.Undefined {
+		if url := loadedURL(target); url != "" {
+			return errors.New("freeboard: couldn't load " + url), true
+		}
+		if tagName := target.Get("tagName"); tagName != nil && tagName != js.Undefined {
+			return nil, false
+		}
+	}
+	if msg := event.Get("message"); msg != nil && msg != js.Undefined && msg.String() != "" {
+		return errors.New("freeboard: " + msg.String()), false
+	}
+	return nil, false
+}
+
+// loadedURL returns the URL of a script or stylesheet element, or "" if
+// el is neither.
+func loadedURL(el *js.Object) string {
+	tagName := el.Get("tagName")
+	if tagName == nil || tagName == js.Undefined {
+		return ""
+	}
+	attr := ""
+	switch strings.ToUpper(tagName.String()) {
+	case "SCRIPT":
+		attr = "src"
+	case "LINK":
+		if rel := el.Get("rel"); rel == nil || rel == js.Undefined || strings.ToLower(rel.String()) != "stylesheet" {
+			return ""
+		}
+		attr = "href"
+	default:
+		return ""
+	}
+	if url := el.Get(attr); url != nil && url != js.Undefined {
+		return url.String()
+	}
+	return ""
+}
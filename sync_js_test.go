@@ -0,0 +1,71 @@
+//go:build js
+// +build js
+
+package freeboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+func TestPageError(t *testing.T) {
+	for _, tc := range []struct {
+		event string
+		want  string
+		fatal bool
+	}{
+		{`{"target": {"tagName": "SCRIPT", "src": "plugins/missing.js"}}`, "freeboard: couldn't load plugins/missing.js", true},
+		{`{"target": {"tagName": "LINK", "rel": "stylesheet", "href": "css/missing.css"}}`, "freeboard: couldn't load css/missing.css", true},
+		{`{"target": {"tagName": "LINK", "rel": "icon", "href": "favicon.ico"}}`, "", false},
+		{`{"target": {"tagName": "IMG", "src": "http://example.com/broken.png"}}`, "", false},
+		{`{"target": {"tagName": "IFRAME", "src": "http://example.com/"}}`, "", false},
+		{`{"target": {}, "message": "Uncaught TypeError: x is undefined"}`, "freeboard: Uncaught TypeError: x is undefined", false},
+		{`{"target": {}}`, "", false},
+	} {
+		err, fatal := pageError(js.Global.Get("JSON").Call("parse", tc.event))
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want || fatal != tc.fatal {
+			t.Errorf("pageError(%s) = %q, %v; want %q, %v", tc.event, got, fatal, tc.want, tc.fatal)
+		}
+	}
+}
+
+func TestWaitForFreeboardReportsErrors(t *testing.T) {
+	window := js.Global.Get("EventTarget").New()
+	js.Global.Set("window", window)
+	defer js.Global.Delete("window")
+	defer func(timeout time.Duration) { SyncTimeout = timeout }(SyncTimeout)
+	SyncTimeout = 50 * time.Millisecond
+
+	// An exception, and then no word from freeboard.
+	err := waitForFreeboard(func(done func()) error {
+		event := js.Global.Get("Event").New("error")
+		event.Set("message", "plugin exploded")
+		window.Call("dispatchEvent", event)
+		return nil
+	})
+	if err == nil || err.Error() != "freeboard: plugin exploded" {
+		t.Errorf("got %v after an exception, want it", err)
+	}
+
+	// An exception, but freeboard finishes anyway.
+	err = waitForFreeboard(func(done func()) error {
+		event := js.Global.Get("Event").New("error")
+		event.Set("message", "harmless")
+		window.Call("dispatchEvent", event)
+		done()
+		return nil
+	})
+	if err != nil {
+		t.Errorf("got %v after freeboard finished", err)
+	}
+
+	if err := waitForFreeboard(func(func()) error { return nil }); err != ErrTimeout {
+		t.Errorf("got %v with no word at all, want ErrTimeout", err)
+	}
+}
@@ -0,0 +1,372 @@
+package freeboard
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// Table layout, in pixels, used to work out the height in blocks.
+const (
+	tableRowHeight    = 24
+	tableHeaderHeight = 26
+	tableTitleHeight  = 30
+)
+
+// tableColumn is a column, from the columns setting.
+type tableColumn struct {
+	key, header, format, align string
+}
+
+// tableWidget is the instance type of TableWidget.
+type tableWidget struct {
+	*BaseWidget
+	// sortKey is the key of the column sorted on, if any.
+	sortKey  string
+	sortDesc bool
+	// rules are from the row_rules setting, and colours by rule.
+	rules   []Rule
+	colours []string
+}
+
+// OnCalculatedValueChanged satisfies the WidgetPlugin interface.
+func (tw *tableWidget) OnCalculatedValueChanged(settingName string, newValue interface{}) {
+	tw.BaseWidget.OnCalculatedValueChanged(settingName, newValue)
+	tw.SetHeight(tableWidgetHeight(tw.Settings(), newValue))
+}
+
+// OnSettingsChanged satisfies the WidgetPlugin interface.
+func (tw *tableWidget) OnSettingsChanged(settings *js.Object) {
+	tw.setRules(settings)
+	tw.BaseWidget.OnSettingsChanged(settings)
+	tw.SetHeight(tableWidgetHeight(settings, tw.Value("rows")))
+}
+
+// Draw renders the table and attaches the sorting handlers.
+func (tw *tableWidget) Draw(state WidgetState) {
+	s := state.Settings
+	rows := tableRows(state.Values["rows"])
+	columns := tableColumns(s, rows)
+
+	tw.mu.Lock()
+	sortKey, sortDesc := tw.sortKey, tw.sortDesc
+	rules, colours := tw.rules, tw.colours
+	tw.mu.Unlock()
+	if sortKey != "" {
+		sort.Stable(tableSorter{rows, sortKey, sortDesc})
+	}
+	if max := int(settingFloat(s, "max_rows", 0)); max > 0 && len(rows) > max {
+		rows = rows[:max]
+	}
+
+	var b bytes.Buffer
+	if title := settingString(s, "title"); title != "" {
+		fmt.Fprintf(&b, `<h2 class="section-title">%s</h2>`, html.EscapeString(title))
+	}
+	b.WriteString(`<table class="gfb-table" style="width: 100%; border-collapse: collapse; font-size: 13px"><thead><tr>`)
+	for _, c := range columns {
+		arrow := ""
+		if c.key == sortKey {
+			arrow = " ▲"
+			if sortDesc {
+				arrow = " ▼"
+			}
+		}
+		fmt.Fprintf(&b, `<th data-key="%s" style="text-align: %s; cursor: pointer; padding: 2px 4px; border-bottom: 1px solid #3d3d3d">%s%s</th>`,
+			html.EscapeString(c.key), html.EscapeString(c.align), html.EscapeString(c.header), arrow)
+	}
+	b.WriteString(`</tr></thead><tbody>`)
+	for _, row := range rows {
+		colour := ""
+		if res := evaluateRules(rules, row); res.Rule >= 0 {
+			colour = colours[res.Rule]
+		}
+		if colour != "" {
+			fmt.Fprintf(&b, `<tr style="color: %s">`, html.EscapeString(colour))
+		} else {
+			b.WriteString(`<tr>`)
+		}
+		for _, c := range columns {
+			fmt.Fprintf(&b, `<td style="text-align: %s; padding: 2px 4px; white-space: nowrap; overflow: hidden">%s</td>`,
+				html.EscapeString(c.align), html.EscapeString(FormatCell(lookupPath(row, c.key), c.format)))
+		}
+		b.WriteString(`</tr>`)
+	}
+	b.WriteString(`</tbody></table>`)
+	state.Container.SetInnerHTML(b.String())
+
+	for _, th := range state.Container.QuerySelectorAll(".gfb-table th") {
+		key := th.GetAttribute("data-key")
+		th.AddEventListener("click", false, func(dom.Event) {
+			tw.mu.Lock()
+			if tw.sortKey == key {
+				tw.sortDesc = !tw.sortDesc
+			} else {
+				tw.sortKey, tw.sortDesc = key, false
+			}
+			tw.mu.Unlock()
+			tw.Redraw()
+		})
+	}
+}
+
+// tableRows reads the rows setting's value as an array of objects;
+// elements that aren't objects are skipped.
+func tableRows(v interface{}) []map[string]interface{} {
+	arr, _ := v.([]interface{})
+	rows := make([]map[string]interface{}, 0, len(arr))
+	for _, el := range arr {
+		if row, ok := el.(map[string]interface{}); ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// tableColumns reads the columns setting. With no columns set, there is
+// one column per key of the first row, in alphabetical order.
+func tableColumns(s *js.Object, rows []map[string]interface{}) []tableColumn {
+	var columns []tableColumn
+	for _, row := range settingRows(s, "columns") {
+		c := tableColumn{
+			key:    settingString(row, "key"),
+			header: settingString(row, "header"),
+			format: settingString(row, "format"),
+			align:  strings.ToLower(settingString(row, "align")),
+		}
+		if c.key == "" {
+			continue
+		}
+		if c.header == "" {
+			c.header = c.key
+		}
+		if c.align != "right" && c.align != "center" {
+			c.align = "left"
+		}
+		columns = append(columns, c)
+	}
+	if len(columns) > 0 || len(rows) == 0 {
+		return columns
+	}
+	keys := make([]string, 0, len(rows[0]))
+	for k := range rows[0] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		columns = append(columns, tableColumn{key: k, header: k, align: "left"})
+	}
+	return columns
+}
+
+// setRules reads the row_rules setting. Every rule has the same
+// severity, so that the first to match wins.
+func (tw *tableWidget) setRules(s *js.Object) {
+	var rules []Rule
+	var colours []string
+	var errs []error
+	for i, row := range settingRows(s, "row_rules") {
+		r, err := CompareRule(settingString(row, "key"), settingString(row, "op"), settingString(row, "value"), SeverityWarning)
+		if err != nil {
+			errs = append(errs, rowError(i, err))
+			continue
+		}
+		rules = append(rules, r)
+		colours = append(colours, settingString(row, "colour"))
+	}
+	tw.mu.Lock()
+	tw.rules, tw.colours = rules, colours
+	tw.mu.Unlock()
+	tw.SetErrors(errs)
+}
+
+// FormatCell formats a value according to a column format:
+//
+//	""            numbers in the locale's default style, anything else as is
+//	"number:N"    a number with N decimal places ("number" leaves it to the locale)
+//	"si:N"        a number with an SI prefix and N decimal places
+//	"percent:N"   a fraction as a percentage with N decimal places
+//	"date"        a JS millisecond timestamp or date string as a local date and time
+//
+// Values that don't suit the format are shown as they are.
+func FormatCell(v interface{}, format string) string {
+	name, arg := format, ""
+	if i := strings.Index(format, ":"); i >= 0 {
+		name, arg = format[:i], format[i+1:]
+	}
+	decimals := -1
+	if d, err := strconv.Atoi(arg); err == nil {
+		decimals = d
+	}
+	n, isNum := toFloat(v)
+	switch strings.TrimSpace(name) {
+	case "":
+		if f, ok := v.(float64); ok {
+			return FormatNumber(f, -1, false)
+		}
+	case "number":
+		if isNum {
+			return FormatNumber(n, decimals, false)
+		}
+	case "si":
+		if isNum {
+			return FormatNumber(n, decimals, true)
+		}
+	case "percent":
+		if isNum {
+			return FormatNumber(n*100, decimals, false) + "%"
+		}
+	case "date":
+		if s := formatDate(v); s != "" {
+			return s
+		}
+	}
+	return displayValue(v)
+}
+
+// formatDate formats a timestamp or date string in the browser's locale,
+// or returns "" if v isn't a date.
+func formatDate(v interface{}) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = ""
+		}
+	}()
+	var d *js.Object
+	if n, ok := toFloat(v); ok {
+		d = js.Global.Get("Date").New(n)
+	} else if str, ok := v.(string); ok {
+		d = js.Global.Get("Date").New(str)
+	} else {
+		return ""
+	}
+	if math.IsNaN(d.Call("getTime").Float()) {
+		return ""
+	}
+	return d.Call("toLocaleString").String()
+}
+
+// tableSorter sorts rows by a key, numerically where both values are
+// numbers and otherwise as text. Missing values sort last.
+type tableSorter struct {
+	rows []map[string]interface{}
+	key  string
+	desc bool
+}
+
+func (ts tableSorter) Len() int      { return len(ts.rows) }
+func (ts tableSorter) Swap(i, j int) { ts.rows[i], ts.rows[j] = ts.rows[j], ts.rows[i] }
+func (ts tableSorter) Less(i, j int) bool {
+	a, b := lookupPath(ts.rows[i], ts.key), lookupPath(ts.rows[j], ts.key)
+	if a == nil || b == nil {
+		return a != nil && b == nil
+	}
+	var less bool
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			if x == y {
+				return false
+			}
+			less = x < y
+		} else {
+			less = true
+		}
+	} else if _, ok := toFloat(b); ok {
+		less = false
+	} else {
+		sa, sb := displayValue(a), displayValue(b)
+		if sa == sb {
+			return false
+		}
+		less = sa < sb
+	}
+	if ts.desc {
+		return !less
+	}
+	return less
+}
+
+// tableWidgetHeight fits the title, header and shown rows into 45-pixel
+// blocks.
+func tableWidgetHeight(settings *js.Object, rowsValue interface{}) int {
+	n := len(tableRows(rowsValue))
+	if max := int(settingFloat(settings, "max_rows", 0)); max > 0 && n > max {
+		n = max
+	}
+	px := tableHeaderHeight + n*tableRowHeight
+	if settingString(settings, "title") != "" {
+		px += tableTitleHeight
+	}
+	blocks := (px + 44) / 45
+	if blocks < 1 {
+		blocks = 1
+	}
+	return blocks
+}
+
+// TableWidget shows a calculated array of objects as a table, one row per
+// object. Columns may be given in its columns setting, or are otherwise
+// taken from the first row. Clicking a header sorts by that column, and
+// rows can be coloured by rules on their values.
+var TableWidget = WtPluginDefinition{
+	TypeName:    "gofreeboard_table",
+	DisplayName: "Table (Go)",
+	Description: "A sortable table of an array of objects.",
+	Settings: []FBSetting{
+		FBSetting{
+			Name:        "title",
+			DisplayName: "Title",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "rows",
+			DisplayName: "Rows",
+			Description: "An array of objects, one per row.",
+			Type:        SettingCalculatedType,
+		},
+		FBSetting{
+			Name:        "columns",
+			DisplayName: "Columns",
+			Description: "Key may be a dotted path such as host.name. Format is blank, number:N, si:N, percent:N or date. Align is left, right or center. Leave empty to show every key of the first row.",
+			Type:        SettingArrayType,
+			Settings: []FBSettingSet{
+				FBSettingSet{Name: "key", DisplayName: "Key", Type: SettingTextType},
+				FBSettingSet{Name: "header", DisplayName: "Header", Type: SettingTextType},
+				FBSettingSet{Name: "format", DisplayName: "Format", Type: SettingTextType},
+				FBSettingSet{Name: "align", DisplayName: "Align", Type: SettingTextType},
+			},
+		},
+		FBSetting{
+			Name:        "row_rules",
+			DisplayName: "Row Colours",
+			Description: "The first rule whose key's value matches colours the row. Op: " + ComparisonHelp,
+			Type:        SettingArrayType,
+			Settings: []FBSettingSet{
+				FBSettingSet{Name: "key", DisplayName: "Key", Type: SettingTextType},
+				FBSettingSet{Name: "op", DisplayName: "Op", Type: SettingTextType},
+				FBSettingSet{Name: "value", DisplayName: "Value", Type: SettingTextType},
+				FBSettingSet{Name: "colour", DisplayName: "Colour", Type: SettingTextType},
+			},
+		},
+		FBSetting{
+			Name:        "max_rows",
+			DisplayName: "Maximum Rows",
+			Description: "Leave blank to show every row.",
+			Type:        SettingNumberType,
+		},
+	},
+	NewInstance: func(settings *js.Object) WidgetPlugin {
+		tw := new(tableWidget)
+		tw.BaseWidget = NewBaseWidget(settings, tw.Draw)
+		tw.setRules(settings)
+		tw.SetHeight(tableWidgetHeight(settings, nil))
+		return tw
+	},
+}
@@ -0,0 +1,161 @@
+package freeboard
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// TemplateData is the data a TemplateWidgetDefinition's template is
+// executed with, e.g. {{.Values.temperature}} or {{.Settings.title}}.
+type TemplateData struct {
+	// Settings are the widget's current settings.
+	Settings map[string]interface{}
+	// Values are the latest calculated values, by setting name.
+	Values map[string]interface{}
+}
+
+// TemplateWidgetDefinition defines a widget whose content is an
+// html/template, re-executed into its container whenever the settings or
+// a calculated value change. The template's contextual escaping applies,
+// so values from datasources can't inject markup.
+type TemplateWidgetDefinition struct {
+	// TypeName should be a unique name for this plugin.
+	// Must be a valid JS name. Avoid potential naming conflicts!
+	TypeName string
+
+	// The displayed name, need not be unique.
+	DisplayName string
+
+	// Front-facing description of this plugin.
+	Description string
+
+	// FillSize is as for WtPluginDefinition.
+	FillSize bool
+
+	// Template is the html/template source. If it is empty, the widget
+	// gets a "template" setting, and the user enters the template.
+	Template string
+
+	// Funcs are made available to the template.
+	Funcs template.FuncMap
+
+	// Settings are the user-facing options for this plugin. Calculated
+	// settings are how datasource values get into the template.
+	Settings []FBSetting
+
+	// Height is the widget's height in 45-pixel blocks. If it is zero,
+	// the widget gets a "height" setting, defaulting to 1.
+	Height int
+}
+
+// templateWidget is the instance type of a TemplateWidgetDefinition.
+type templateWidget struct {
+	*BaseWidget
+	def TemplateWidgetDefinition
+	// tmpl is the parsed template, and source the text it was parsed from.
+	tmpl   *template.Template
+	source string
+	err    error
+}
+
+// OnSettingsChanged satisfies the WidgetPlugin interface.
+func (tw *templateWidget) OnSettingsChanged(settings *js.Object) {
+	tw.SetHeight(tw.def.height(settings))
+	tw.BaseWidget.OnSettingsChanged(settings)
+}
+
+// Draw executes the template into the container, or shows why it can't.
+func (tw *templateWidget) Draw(state WidgetState) {
+	source := tw.def.Template
+	if source == "" {
+		source = settingString(state.Settings, "template")
+	}
+	if tw.tmpl == nil || source != tw.source {
+		tw.tmpl, tw.err = template.New(tw.def.TypeName).Funcs(tw.def.Funcs).Parse(source)
+		tw.source = source
+	}
+	var b bytes.Buffer
+	err := tw.err
+	if err == nil {
+		data := TemplateData{Settings: make(map[string]interface{}), Values: state.Values}
+		if m, ok := state.Settings.Interface().(map[string]interface{}); ok {
+			data.Settings = m
+		}
+		err = tw.tmpl.Execute(&b, data)
+	}
+	if err != nil {
+		state.Container.SetInnerHTML(`<div class="go-freeboard-error" style="color: #ff6b6b">` + html.EscapeString(err.Error()) + `</div>`)
+		return
+	}
+	state.Container.SetInnerHTML(b.String())
+}
+
+// height is the fixed Height, or the height setting.
+func (twd TemplateWidgetDefinition) height(settings *js.Object) int {
+	if twd.Height > 0 {
+		return twd.Height
+	}
+	if h := int(settingFloat(settings, "height", 1)); h > 0 {
+		return h
+	}
+	return 1
+}
+
+// WtPluginDefinition converts the definition into a widget definition,
+// ready for FBWrapper.AddWidgetPlugin.
+func (twd TemplateWidgetDefinition) WtPluginDefinition() WtPluginDefinition {
+	settings := make([]FBSetting, 0, len(twd.Settings)+2)
+	if twd.Template == "" {
+		settings = append(settings, FBSetting{
+			Name:        "template",
+			DisplayName: "Template",
+			Description: "A Go html/template. Settings are in .Settings and calculated values in .Values, e.g. {{.Values.data}}.",
+			Type:        SettingTextType,
+		})
+	}
+	settings = append(settings, twd.Settings...)
+	if twd.Height <= 0 {
+		settings = append(settings, FBSetting{
+			Name:            "height",
+			DisplayName:     "Height Blocks",
+			Type:            SettingNumberType,
+			DefaultIntValue: 1,
+		})
+	}
+	return WtPluginDefinition{
+		TypeName:    twd.TypeName,
+		DisplayName: twd.DisplayName,
+		Description: twd.Description,
+		FillSize:    twd.FillSize,
+		Settings:    settings,
+		NewInstance: func(settings *js.Object) WidgetPlugin {
+			tw := &templateWidget{def: twd}
+			tw.BaseWidget = NewBaseWidget(settings, tw.Draw)
+			tw.SetHeight(twd.height(settings))
+			return tw
+		},
+	}
+}
+
+// ToFBInterface returns a map for FreeBoard's loadWidgetPlugin func.
+func (twd TemplateWidgetDefinition) ToFBInterface() map[string]interface{} {
+	return twd.WtPluginDefinition().ToFBInterface()
+}
+
+// TemplateWidget is a widget whose template is entered as a setting, with
+// one calculated setting, data, available as {{.Values.data}}.
+var TemplateWidget = TemplateWidgetDefinition{
+	TypeName:    "gofreeboard_template",
+	DisplayName: "Template (Go)",
+	Description: "Custom HTML from a Go template and a calculated value.",
+	Settings: []FBSetting{
+		FBSetting{
+			Name:        "data",
+			DisplayName: "Data",
+			Type:        SettingCalculatedType,
+		},
+	},
+}.WtPluginDefinition()
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/cathalgarvey/go-freeboard"
 	"github.com/gopherjs/gopherjs/js"
 )
@@ -20,7 +22,7 @@ func (tp *CatsPlugin) CurrentSettings() *js.Object {
 // OnSettingsChanged satisfies the freeboard.DsPlugin interface.
 func (tp *CatsPlugin) OnSettingsChanged(settings *js.Object) {
 	tp.settings = settings
-	tp.UpdateNow()
+	freeboard.RequestUpdate(tp)
 }
 
 // UpdateNow satisfies the freeboard.DsPlugin interface.
@@ -91,7 +93,24 @@ var TestDefinition = freeboard.DsPluginDefinition{
 	},
 }
 
-func main() {
+// register loads the plugins into a host, which is freeboard.FB in the
+// browser.
+func register(host freeboard.Host) error {
 	println("Registering plugin")
-	freeboard.FB.LoadGoDatasourcePlugin(TestDefinition)
+	if err := host.AddDatasourcePlugin(TestDefinition); err != nil {
+		return err
+	}
+	println("Registering widget")
+	return host.AddWidgetPlugin(CatsWidgetDefinition)
+}
+
+func main() {
+	fb, err := freeboard.WaitForFreeboard(10 * time.Second)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	if err := register(fb); err != nil {
+		println(err.Error())
+	}
 }
@@ -0,0 +1,51 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cathalgarvey/go-freeboard/freeboardtest"
+)
+
+func TestCats(t *testing.T) {
+	host := freeboardtest.NewHost()
+	if err := register(host); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := host.NewDatasource("cats", "catsplugin", map[string]interface{}{"animal": "Tiger"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Dispose()
+	if err := ds.UpdateNow(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.WaitForPayloads(1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	var cats struct {
+		Animal string `json:"animal"`
+	}
+	if err := ds.DecodePayload(0, &cats); err != nil || cats.Animal != "Tiger" {
+		t.Errorf("payload is %+v, %v, want the Tiger", cats, err)
+	}
+
+	if err := ds.SetSettings(map[string]interface{}{"animal": "Liger"}); err != nil {
+		t.Fatal(err)
+	}
+	payloads, err := ds.WaitForPayloads(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.DecodePayload(len(payloads)-1, &cats); err != nil || cats.Animal != "Liger" {
+		t.Errorf("after new settings, payload is %+v, %v, want the Liger", cats, err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	freeboardtest.RunDatasourceConformance(t, TestDefinition)
+	freeboardtest.RunWidgetConformance(t, CatsWidgetDefinition)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/cathalgarvey/go-freeboard"
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// CatsWidget shows a calculated animal under a title.
+type CatsWidget struct {
+	settings  *js.Object
+	animal    interface{}
+	container dom.HTMLElement
+}
+
+// OnSettingsChanged satisfies the freeboard.WidgetPlugin interface.
+func (cw *CatsWidget) OnSettingsChanged(settings *js.Object) {
+	cw.settings = settings
+	cw.draw()
+}
+
+// OnCalculatedValueChanged satisfies the freeboard.WidgetPlugin interface.
+func (cw *CatsWidget) OnCalculatedValueChanged(settingName string, newValue interface{}) {
+	if settingName == "animal" {
+		cw.animal = newValue
+	}
+	cw.draw()
+}
+
+// Render satisfies the freeboard.WidgetPlugin interface.
+func (cw *CatsWidget) Render(containerElement dom.HTMLElement) {
+	cw.container = containerElement
+	cw.draw()
+}
+
+// GetHeight satisfies the freeboard.WidgetPlugin interface.
+func (cw *CatsWidget) GetHeight() int {
+	return 1
+}
+
+// OnDispose satisfies the freeboard.WidgetPlugin interface.
+func (cw *CatsWidget) OnDispose() {
+	cw.container = nil
+}
+
+// draw redraws the widget, if it has been rendered.
+func (cw *CatsWidget) draw() {
+	if cw.container == nil {
+		return
+	}
+	cw.container.SetTextContent(fmt.Sprintf("%s: %v", cw.settings.Get("title"), cw.animal))
+}
+
+// CatsWidgetDefinition defines a widget showing a calculated animal.
+var CatsWidgetDefinition = freeboard.WtPluginDefinition{
+	TypeName:    "catswidget",
+	DisplayName: "Cats",
+	Description: "This is a demo Golang widget about cats",
+	Settings: []freeboard.FBSetting{
+		freeboard.FBSetting{
+			Name:               "title",
+			DisplayName:        "Title",
+			Type:               freeboard.SettingTextType,
+			DefaultStringValue: "Animal",
+		},
+		freeboard.FBSetting{
+			Name:        "animal",
+			DisplayName: "Animal",
+			Description: "Try the Cats datasource's animal.",
+			Type:        freeboard.SettingCalculatedType,
+		},
+	},
+	NewInstance: func(settings *js.Object) freeboard.WidgetPlugin {
+		return &CatsWidget{settings: settings}
+	},
+}
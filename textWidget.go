@@ -0,0 +1,233 @@
+package freeboard
+
+import (
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// textWidget is the instance type of TextWidget.
+type textWidget struct {
+	*BaseWidget
+	lastValue         interface{}
+	lastChange        time.Time
+	stale             bool
+	closeToKillTicker chan interface{}
+	rules             *RuleSet
+	closeToKillRules  chan interface{}
+}
+
+// OnSettingsChanged satisfies the WidgetPlugin interface.
+func (tw *textWidget) OnSettingsChanged(settings *js.Object) {
+	rules, errs := RulesFromSettings(settings, RulesSetting.Name)
+	tw.rules.SetRules(rules)
+	tw.SetErrors(errs)
+	tw.SetHeight(textWidgetHeight(settings))
+	tw.BaseWidget.OnSettingsChanged(settings)
+}
+
+// OnCalculatedValueChanged satisfies the WidgetPlugin interface.
+func (tw *textWidget) OnCalculatedValueChanged(settingName string, newValue interface{}) {
+	if settingName == "value" {
+		tw.mu.Lock()
+		if tw.lastChange.IsZero() || displayValue(newValue) != displayValue(tw.lastValue) {
+			tw.lastValue = newValue
+			tw.lastChange = time.Now()
+			tw.stale = false
+		}
+		tw.mu.Unlock()
+		tw.rules.Observe(newValue)
+	}
+	tw.BaseWidget.OnCalculatedValueChanged(settingName, newValue)
+}
+
+// OnDispose satisfies the WidgetPlugin interface.
+func (tw *textWidget) OnDispose() {
+	close(tw.closeToKillTicker)
+	close(tw.closeToKillRules)
+	tw.BaseWidget.OnDispose()
+}
+
+// checkStale redraws if the value has become stale since the last check.
+func (tw *textWidget) checkStale() {
+	staleAfter := settingFloat(tw.Settings(), "stale_after", 0)
+	tw.mu.Lock()
+	stale := staleAfter > 0 && !tw.lastChange.IsZero() &&
+		time.Since(tw.lastChange) > time.Duration(staleAfter*float64(time.Second))
+	changed := stale != tw.stale
+	tw.stale = stale
+	tw.mu.Unlock()
+	if changed {
+		tw.Redraw()
+	}
+}
+
+// Draw renders the title, value and units into the container.
+func (tw *textWidget) Draw(state WidgetState) {
+	tw.mu.Lock()
+	stale := tw.stale
+	tw.mu.Unlock()
+	alert := tw.rules.Evaluate()
+	s := state.Settings
+	doc := dom.GetWindow().Document()
+	state.Container.SetInnerHTML("")
+
+	if title := settingString(s, "title"); title != "" {
+		h := doc.CreateElement("h2").(dom.HTMLElement)
+		h.Class().SetString("section-title")
+		h.SetTextContent(title)
+		state.Container.AppendChild(h)
+	}
+
+	display := doc.CreateElement("div").(dom.HTMLElement)
+	display.Class().SetString("tw-display")
+	display.Class().Add(alert.Class)
+	if colour := severityColours[alert.Severity]; colour != "" {
+		display.Style().SetProperty("color", colour, "")
+	}
+	if stale {
+		display.Class().Add("go-freeboard-stale")
+		display.Style().SetProperty("opacity", "0.5", "")
+		display.SetTitle("No change for " + settingString(s, "stale_after") + " seconds")
+	}
+	value := doc.CreateElement("div").(dom.HTMLElement)
+	value.Class().SetString("tw-value")
+	fontSize := "30px"
+	if settingString(s, "size") == "big" {
+		fontSize = "75px"
+	}
+	value.Style().SetProperty("font-size", fontSize, "")
+	value.SetTextContent(settingString(s, "prefix") + formatTextValue(s, state.Values["value"]) + settingString(s, "suffix"))
+	display.AppendChild(value)
+
+	if units := settingString(s, "units"); units != "" {
+		u := doc.CreateElement("div").(dom.HTMLElement)
+		u.Class().SetString("tw-unit")
+		u.SetTextContent(units)
+		display.AppendChild(u)
+	}
+	if stale {
+		badge := doc.CreateElement("span").(dom.HTMLElement)
+		badge.Class().SetString("go-freeboard-stale-badge")
+		badge.Style().SetProperty("font-size", "11px", "")
+		badge.Style().SetProperty("margin-left", "6px", "")
+		badge.SetTextContent("stale")
+		display.AppendChild(badge)
+	}
+	state.Container.AppendChild(display)
+	if alert.Message != "" {
+		msg := doc.CreateElement("div").(dom.HTMLElement)
+		msg.Class().SetString("gfb-alert-message")
+		msg.Style().SetProperty("font-size", "12px", "")
+		msg.SetTextContent(alert.Message)
+		state.Container.AppendChild(msg)
+	}
+}
+
+// severityColours colour the text widget's value by alert severity.
+var severityColours = map[Severity]string{
+	SeverityWarning:  "#ffc107",
+	SeverityCritical: "#ff5252",
+}
+
+// formatTextValue formats a value according to the decimals and si
+// settings, if it is a number.
+func formatTextValue(s *js.Object, v interface{}) string {
+	n, ok := toFloat(v)
+	if !ok {
+		return displayValue(v)
+	}
+	decimals := -1
+	if settingString(s, "decimals") != "" {
+		decimals = int(settingFloat(s, "decimals", -1))
+	}
+	return FormatNumber(n, decimals, settingBool(s, "si_prefix"))
+}
+
+// textWidgetHeight is 1 block for regular text and 2 for big.
+func textWidgetHeight(settings *js.Object) int {
+	if settingString(settings, "size") == "big" {
+		return 2
+	}
+	return 1
+}
+
+// TextWidget is a Go counterpart of freeboard's text widget, showing a
+// calculated value with a title, units, a prefix and a suffix. Numbers are
+// formatted in the browser's locale, optionally with SI prefixes, and the
+// value is dimmed and marked "stale" if it hasn't changed for a while.
+// Alert rules, as RulesSetting, colour the value and can add a message.
+// To extend it, copy the definition and add to its Settings, or wrap its
+// NewInstance.
+var TextWidget = WtPluginDefinition{
+	TypeName:    "gofreeboard_text",
+	DisplayName: "Text (Go)",
+	Description: "A value with units and formatting, and a staleness indicator.",
+	Settings: []FBSetting{
+		FBSetting{
+			Name:        "title",
+			DisplayName: "Title",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "size",
+			DisplayName: "Size",
+			Type:        SettingOptionType,
+			Options: []FBSettingOpt{
+				FBSettingOpt{Name: "Regular", Value: "regular"},
+				FBSettingOpt{Name: "Big", Value: "big"},
+			},
+		},
+		FBSetting{
+			Name:        "value",
+			DisplayName: "Value",
+			Type:        SettingCalculatedType,
+		},
+		FBSetting{
+			Name:        "decimals",
+			DisplayName: "Decimal Places",
+			Description: "Leave blank to use the locale's default.",
+			Type:        SettingNumberType,
+		},
+		FBSetting{
+			Name:        "si_prefix",
+			DisplayName: "SI Prefixes",
+			Description: "Scale by k, M, m and so on, e.g. 12345 as 12.345k; the decimals shown follow Decimal Places, or the locale.",
+			Type:        SettingBooleanType,
+		},
+		FBSetting{
+			Name:        "prefix",
+			DisplayName: "Prefix",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "suffix",
+			DisplayName: "Suffix",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "units",
+			DisplayName: "Units",
+			Type:        SettingTextType,
+		},
+		FBSetting{
+			Name:        "stale_after",
+			DisplayName: "Stale After",
+			Description: "Seconds without a change before the value is marked stale. Leave blank to never mark it.",
+			Type:        SettingNumberType,
+		},
+		RulesSetting,
+	},
+	NewInstance: func(settings *js.Object) WidgetPlugin {
+		tw := new(textWidget)
+		tw.BaseWidget = NewBaseWidget(settings, tw.Draw)
+		tw.SetHeight(textWidgetHeight(settings))
+		tw.closeToKillTicker = makeTicker(time.Second, tw.checkStale)
+		rules, errs := RulesFromSettings(settings, RulesSetting.Name)
+		tw.rules = NewRuleSet(rules)
+		tw.SetErrors(errs)
+		tw.closeToKillRules = tw.rules.Watch(func(RuleResult) { tw.Redraw() })
+		return tw
+	},
+}
@@ -0,0 +1,233 @@
+package freeboard
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// UpdatePolicy controls how the wrapper made by WrapDsPluginPolicy handles
+// calls to UpdateNow that arrive while an earlier call is still running.
+// UpdateNow may be called at once by a ticker from MakeUpdateTicker, by the
+// user's manual refresh and by the plugin's own OnSettingsChanged; plugins
+// should ask for refreshes with RequestUpdate rather than calling their
+// own UpdateNow, so that the policy applies to them too.
+//
+// A refresh is taken to be running until UpdateNow returns. Under
+// UpdateSerial and UpdateCollapse, UpdateNow is called from its own
+// goroutine and may block, e.g. on HTTP, and it should: a plugin that
+// starts a goroutine of its own and returns at once isn't serialised.
+type UpdatePolicy int
+
+const (
+	// UpdateConcurrent calls UpdateNow every time it's asked to, at once.
+	// This is the default, and how freeboard treats JS plugins.
+	UpdateConcurrent UpdatePolicy = iota
+	// UpdateSerial runs calls one at a time, in the order they arrived.
+	UpdateSerial
+	// UpdateCollapse runs at most one more call after the running one;
+	// all calls that arrive in the meantime are merged into it.
+	UpdateCollapse
+)
+
+// CancellableDsPlugin is a DsPlugin whose refreshes can be abandoned.
+// If a plugin implements it, the wrapper calls UpdateNowContext instead
+// of UpdateNow, and cancels the context when new settings arrive or the
+// plugin is disposed. Plugins should check ctx before sending results,
+// so that a refresh made with old settings doesn't overwrite a newer one.
+type CancellableDsPlugin interface {
+	DsPlugin
+	UpdateNowContext(ctx context.Context)
+}
+
+// updateFlight stands between freeboard and a DsPlugin, applying an
+// UpdatePolicy to calls of UpdateNow. Under UpdateSerial and UpdateCollapse
+// it also drops the results of a refresh that new settings or disposal
+// have superseded, if they arrive while the refresh is running, so that
+// plugins that can't be cancelled don't overwrite newer results.
+type updateFlight struct {
+	sync.Mutex
+	dsp      DsPlugin
+	policy   UpdatePolicy
+	running  bool
+	queued   int
+	disposed bool
+	guard    *pluginGuard
+	ctx      context.Context
+	cancel   context.CancelFunc
+	// current is the context of the refresh being run by drain, or nil.
+	current context.Context
+	// started, if set, is called as each refresh starts, however it was
+	// asked for.
+	started func()
+}
+
+// updateFlights holds every live updateFlight, so that tickers made by
+// MakeUpdateTicker go through the same policy as freeboard's calls.
+// It's a list rather than a map keyed by plugin, since plugins needn't
+// be comparable.
+var updateFlights = struct {
+	sync.Mutex
+	all []*updateFlight
+}{}
+
+// newUpdateFlight returns a flight with no plugin yet; see attach.
+func newUpdateFlight(policy UpdatePolicy, guard *pluginGuard) *updateFlight {
+	uf := &updateFlight{policy: policy, guard: guard}
+	uf.ctx, uf.cancel = context.WithCancel(context.Background())
+	return uf
+}
+
+// attach sets the flight's plugin and registers it for flightFor. The
+// flight is made before the plugin, so that the update callback given
+// to the plugin can be wrapped with wrapUpdate.
+func (uf *updateFlight) attach(dsp DsPlugin) {
+	uf.dsp = dsp
+	updateFlights.Lock()
+	updateFlights.all = append(updateFlights.all, uf)
+	updateFlights.Unlock()
+}
+
+// flightFor returns the updateFlight wrapping dsp, or nil if there is none.
+func flightFor(dsp DsPlugin) *updateFlight {
+	updateFlights.Lock()
+	defer updateFlights.Unlock()
+	for _, uf := range updateFlights.all {
+		if samePlugin(uf.dsp, dsp) {
+			return uf
+		}
+	}
+	return nil
+}
+
+// samePlugin reports whether a and b are the same plugin. Comparing
+// plugins of types that aren't comparable panics; they're never the same.
+func samePlugin(a, b DsPlugin) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
+
+// RequestUpdate asks for a refresh of dsp through its wrapper's
+// UpdatePolicy, as a ticker made by MakeUpdateTicker does. Plugins
+// should call it, rather than their own UpdateNow, e.g. from
+// OnSettingsChanged. If dsp hasn't been wrapped, it calls UpdateNow.
+func RequestUpdate(dsp DsPlugin) {
+	if uf := flightFor(dsp); uf != nil {
+		uf.UpdateNow()
+	} else {
+		dsp.UpdateNow()
+	}
+}
+
+// wrapUpdate returns an update callback that drops payloads sent by a
+// superseded refresh, and passes the rest on to update.
+func (uf *updateFlight) wrapUpdate(update func(interface{})) func(interface{}) {
+	return func(payload interface{}) {
+		uf.Lock()
+		superseded := uf.current != nil && uf.current.Err() != nil
+		uf.Unlock()
+		if superseded {
+			return
+		}
+		update(payload)
+	}
+}
+
+// call runs a single refresh of the plugin.
+func (uf *updateFlight) call(ctx context.Context) {
+	if uf.started != nil {
+		uf.started()
+	}
+	if cdsp, ok := uf.dsp.(CancellableDsPlugin); ok {
+		cdsp.UpdateNowContext(ctx)
+		return
+	}
+	uf.dsp.UpdateNow()
+}
+
+// callGuarded is call, recovering panics; refreshes run from drain or
+// from a ticker are outside the reach of the guarded wrapper functions.
+func (uf *updateFlight) callGuarded(ctx context.Context) {
+	defer uf.guard.recover("updateNow")
+	uf.call(ctx)
+}
+
+// UpdateNow asks for a refresh, subject to the policy.
+func (uf *updateFlight) UpdateNow() {
+	uf.Lock()
+	if uf.disposed {
+		uf.Unlock()
+		return
+	}
+	ctx := uf.ctx
+	if uf.policy == UpdateConcurrent {
+		uf.Unlock()
+		uf.callGuarded(ctx)
+		return
+	}
+	if uf.running {
+		if uf.policy == UpdateSerial || uf.queued == 0 {
+			uf.queued++
+		}
+		uf.Unlock()
+		return
+	}
+	uf.running = true
+	uf.Unlock()
+	go uf.drain()
+}
+
+// drain runs refreshes until none are queued.
+func (uf *updateFlight) drain() {
+	for {
+		uf.Lock()
+		ctx := uf.ctx
+		uf.current = ctx
+		uf.Unlock()
+		uf.callGuarded(ctx)
+		uf.Lock()
+		uf.current = nil
+		if uf.queued == 0 || uf.disposed {
+			uf.running = false
+			uf.queued = 0
+			uf.Unlock()
+			return
+		}
+		uf.queued--
+		uf.Unlock()
+	}
+}
+
+// OnSettingsChanged cancels any refresh in flight before passing the
+// settings on to the plugin.
+func (uf *updateFlight) OnSettingsChanged(settings *js.Object) {
+	uf.Lock()
+	uf.cancel()
+	uf.ctx, uf.cancel = context.WithCancel(context.Background())
+	uf.Unlock()
+	uf.dsp.OnSettingsChanged(settings)
+}
+
+// OnDispose cancels any refresh in flight, drops queued refreshes and
+// unregisters the flight before disposing of the plugin.
+func (uf *updateFlight) OnDispose() {
+	uf.Lock()
+	uf.disposed = true
+	uf.queued = 0
+	uf.cancel()
+	uf.Unlock()
+	updateFlights.Lock()
+	for i, other := range updateFlights.all {
+		if other == uf {
+			updateFlights.all = append(updateFlights.all[:i], updateFlights.all[i+1:]...)
+			break
+		}
+	}
+	updateFlights.Unlock()
+	uf.dsp.OnDispose()
+}
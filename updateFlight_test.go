@@ -0,0 +1,149 @@
+package freeboard
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// blockingPlugin is a DsPlugin whose UpdateNow blocks until released,
+// then sends the number of the call.
+type blockingPlugin struct {
+	sync.Mutex
+	release chan struct{}
+	update  func(interface{})
+	calls   int
+	running int
+	most    int
+}
+
+func (bp *blockingPlugin) OnSettingsChanged(*js.Object) {}
+func (bp *blockingPlugin) OnDispose()                   {}
+func (bp *blockingPlugin) CurrentSettings() *js.Object  { return nil }
+
+func (bp *blockingPlugin) UpdateNow() {
+	bp.Lock()
+	bp.calls++
+	call := bp.calls
+	bp.running++
+	if bp.running > bp.most {
+		bp.most = bp.running
+	}
+	bp.Unlock()
+	<-bp.release
+	bp.Lock()
+	bp.running--
+	bp.Unlock()
+	bp.update(call)
+}
+
+// newBlockingFlight wraps a blockingPlugin with policy, recording the
+// payloads that get through.
+func newBlockingFlight(policy UpdatePolicy) (*updateFlight, *blockingPlugin, func() []interface{}) {
+	var mu sync.Mutex
+	var got []interface{}
+	uf := newUpdateFlight(policy, newPluginGuard("blockingPlugin"))
+	bp := &blockingPlugin{release: make(chan struct{})}
+	bp.update = uf.wrapUpdate(func(payload interface{}) {
+		mu.Lock()
+		got = append(got, payload)
+		mu.Unlock()
+	})
+	wrapDsPlugin(bp, uf, uf.guard)
+	return uf, bp, func() []interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]interface{}(nil), got...)
+	}
+}
+
+// waitFor polls cond for up to a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUpdateFlightPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy UpdatePolicy
+		asks   int
+		calls  int
+	}{
+		{"serial", UpdateSerial, 4, 4},
+		{"collapse", UpdateCollapse, 4, 2},
+	} {
+		uf, bp, got := newBlockingFlight(tc.policy)
+		for i := 0; i < tc.asks; i++ {
+			RequestUpdate(bp)
+		}
+		for i := 0; i < tc.calls; i++ {
+			waitFor(t, tc.name+" call", func() bool {
+				bp.Lock()
+				defer bp.Unlock()
+				return bp.running == 1
+			})
+			bp.release <- struct{}{}
+		}
+		waitFor(t, tc.name+" payloads", func() bool { return len(got()) == tc.calls })
+		uf.OnDispose()
+		bp.Lock()
+		if bp.calls != tc.calls || bp.most != 1 {
+			t.Errorf("%s: %d calls, at most %d at once; want %d calls, one at a time", tc.name, bp.calls, bp.most, tc.calls)
+		}
+		bp.Unlock()
+	}
+}
+
+func TestUpdateFlightDropsSuperseded(t *testing.T) {
+	uf, bp, got := newBlockingFlight(UpdateSerial)
+	RequestUpdate(bp)
+	waitFor(t, "the first call", func() bool {
+		bp.Lock()
+		defer bp.Unlock()
+		return bp.running == 1
+	})
+	// New settings supersede the refresh in flight.
+	uf.OnSettingsChanged(nil)
+	bp.release <- struct{}{}
+	waitFor(t, "the flight to finish", func() bool {
+		uf.Lock()
+		defer uf.Unlock()
+		return !uf.running
+	})
+	if payloads := got(); len(payloads) != 0 {
+		t.Errorf("got %v from a superseded refresh", payloads)
+	}
+	RequestUpdate(bp)
+	bp.release <- struct{}{}
+	waitFor(t, "the second payload", func() bool { return len(got()) == 1 })
+	uf.OnDispose()
+}
+
+// unhashablePlugin is a DsPlugin of a comparable type whose values
+// panic when compared, since they hold a slice in an interface.
+type unhashablePlugin struct {
+	v interface{}
+}
+
+func (up unhashablePlugin) OnSettingsChanged(*js.Object) {}
+func (up unhashablePlugin) OnDispose()                   {}
+func (up unhashablePlugin) CurrentSettings() *js.Object  { return nil }
+func (up unhashablePlugin) UpdateNow()                   {}
+
+func TestUpdateFlightUnhashablePlugin(t *testing.T) {
+	up := unhashablePlugin{v: []int{1}}
+	wrapper := WrapDsPluginPolicy(up, UpdateSerial)
+	if flightFor(up) != nil {
+		t.Error("found a flight for a plugin that can't be compared")
+	}
+	RequestUpdate(up)
+	wrapper["onDispose"].(func())()
+}
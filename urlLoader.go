@@ -0,0 +1,248 @@
+package freeboard
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// URLVarPrefix marks dashboard variables among URL parameters, e.g.
+// var-host=db1 sets the variable host.
+const URLVarPrefix = "var-"
+
+// URLParams are the board parameters read from a page's URL:
+//
+//	source=URL      the dashboard to load, as freeboard's own index page takes it
+//	mode=edit|view  whether the board may be edited; also edit=true|false
+//	var-NAME=VALUE  a dashboard variable; see Dashboard.SetVariables
+//
+// Parameters may be in the hash, as in #source=boards/main.json, or the
+// query. Each parameter in the hash overrides the same one in the query,
+// so ?source=a.json#source=b.json loads b.json, and ?mode=view#edit=true
+// is editable. Within the hash or the query, mode overrides edit; of a
+// parameter given twice, the last is used.
+type URLParams struct {
+	Source string
+	// Mode is "edit", "view", or "" if the URL doesn't say.
+	Mode string
+	Vars map[string]string
+}
+
+// ParseURLParams reads parameters from a URL's hash and query, with or
+// without their leading "#" and "?".
+func ParseURLParams(hash, query string) URLParams {
+	p := URLParams{Vars: make(map[string]string)}
+	for _, s := range []string{strings.TrimPrefix(query, "?"), strings.TrimPrefix(hash, "#")} {
+		values, err := url.ParseQuery(s)
+		if err != nil {
+			continue
+		}
+		for k, vs := range values {
+			v := vs[len(vs)-1]
+			switch {
+			case k == "source":
+				p.Source = v
+			case strings.HasPrefix(k, URLVarPrefix):
+				p.Vars[strings.TrimPrefix(k, URLVarPrefix)] = v
+			}
+		}
+		// The map's order is random, so edit and mode are read after it,
+		// in order of precedence.
+		if vs, ok := values["edit"]; ok {
+			p.Mode = "view"
+			if v := vs[len(vs)-1]; v == "" || v == "true" || v == "1" || v == "yes" {
+				p.Mode = "edit"
+			}
+		}
+		if vs, ok := values["mode"]; ok {
+			if v := vs[len(vs)-1]; v == "edit" || v == "view" {
+				p.Mode = v
+			}
+		}
+	}
+	return p
+}
+
+// CurrentURLParams reads the parameters of the page's URL.
+func CurrentURLParams() URLParams {
+	location := js.Global.Get("location")
+	return ParseURLParams(location.Get("hash").String(), location.Get("search").String())
+}
+
+// sameBoard reports whether two sets of parameters load the same board,
+// leaving aside the mode.
+func (p URLParams) sameBoard(other URLParams) bool {
+	if p.Source != other.Source || len(p.Vars) != len(other.Vars) {
+		return false
+	}
+	for k, v := range p.Vars {
+		if ov, ok := other.Vars[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// SetVariables replaces "${NAME}" with the value of variable NAME in the
+// titles and string settings of the board's panes, widgets and
+// datasources, so that one board can serve, e.g., several hosts.
+// Unknown variables are left as they are.
+func (d *Dashboard) SetVariables(vars map[string]string) {
+	if len(vars) == 0 {
+		return
+	}
+	pairs := make([]string, 0, 2*len(vars))
+	for k, v := range vars {
+		pairs = append(pairs, "${"+k+"}", v)
+	}
+	r := strings.NewReplacer(pairs...)
+	for i := range d.Datasources {
+		replaceSettings(r, d.Datasources[i].Settings)
+	}
+	for i := range d.Panes {
+		pane := &d.Panes[i]
+		pane.Title = r.Replace(pane.Title)
+		for j := range pane.Widgets {
+			pane.Widgets[j].Title = r.Replace(pane.Widgets[j].Title)
+			replaceSettings(r, pane.Widgets[j].Settings)
+		}
+	}
+}
+
+// replaceSettings applies r to every string within settings.
+func replaceSettings(r *strings.Replacer, settings map[string]interface{}) {
+	var replace func(v interface{}) interface{}
+	replace = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case string:
+			return r.Replace(v)
+		case []interface{}:
+			for i := range v {
+				v[i] = replace(v[i])
+			}
+		case map[string]interface{}:
+			for k := range v {
+				v[k] = replace(v[k])
+			}
+		}
+		return v
+	}
+	replace(settings)
+}
+
+// InitializeFromURL starts the board as the page's URL says. It calls
+// Initialize, allowing edits if the URL's mode is edit, or if it doesn't
+// say and allowEdit is true; then, if the URL has a source, it fetches
+// the board from there, sets its variables and loads it. finished, which
+// may be nil, is called with the error, if any, once that's done.
+//
+// After that, changes to the hash load the new source or variables, or
+// switch modes, without reloading the page.
+func (fb *FBWrapper) InitializeFromURL(allowEdit bool, finished func(error)) {
+	ul := &urlLoader{fb: fb, params: CurrentURLParams()}
+	if ul.params.Mode != "" {
+		allowEdit = ul.params.Mode == "edit"
+	}
+	err := fb.InitializeBoard(allowEdit, func() {
+		go func() {
+			err := ul.load(ul.params)
+			if finished != nil {
+				finished(err)
+			}
+			js.Global.Call("addEventListener", "hashchange", func() { go ul.hashChanged() })
+		}()
+	})
+	if err != nil && finished != nil {
+		finished(err)
+	}
+}
+
+// urlLoader loads boards as the page's URL says.
+type urlLoader struct {
+	sync.Mutex
+	fb     *FBWrapper
+	params URLParams
+}
+
+// hashChanged loads the board again if the source or variables changed,
+// or otherwise applies the mode.
+func (ul *urlLoader) hashChanged() {
+	params := CurrentURLParams()
+	ul.Lock()
+	same := params.sameBoard(ul.params)
+	modeChanged := params.Mode != ul.params.Mode
+	ul.params = params
+	ul.Unlock()
+	if !same {
+		if err := ul.load(params); err != nil {
+			logError("freeboard: loading "+params.Source+":", err.Error())
+		}
+	} else if modeChanged {
+		ul.applyMode(params.Mode)
+	}
+}
+
+// load fetches and loads the board, if params has a source.
+func (ul *urlLoader) load(params URLParams) error {
+	if params.Source == "" {
+		return nil
+	}
+	resp, err := httpRequest("GET", params.Source, nil, "")
+	if err != nil {
+		return err
+	}
+	if err := resp.err(); err != nil {
+		return err
+	}
+	d, err := ParseDashboard([]byte(resp.body))
+	if err != nil {
+		return err
+	}
+	d.SetVariables(params.Vars)
+	if params.Mode != "" {
+		d.AllowEdit = params.Mode == "edit"
+	}
+	if err := ul.fb.LoadDashboardSync(d); err != nil {
+		return err
+	}
+	ul.applyMode(params.Mode)
+	return nil
+}
+
+// applyMode allows or prevents editing. An empty mode leaves the board
+// as it is.
+func (ul *urlLoader) applyMode(mode string) {
+	if mode == "" {
+		return
+	}
+	editing := mode == "edit"
+	if err := ul.fb.SetEditMode(editing, false); err != nil {
+		logError("freeboard: applying mode", mode+":", err.Error())
+		return
+	}
+	// Freeboard has no API for allow_edit, so it's set on its model.
+	if model := freeboardModel(); model != nil {
+		model.Call("allow_edit", editing)
+	}
+}
+
+// freeboardModel returns freeboard's knockout view model, bound to the
+// page's body, or nil if it can't be found.
+func freeboardModel() (model *js.Object) {
+	defer func() {
+		if recover() != nil {
+			model = nil
+		}
+	}()
+	ko := js.Global.Get("ko")
+	if ko == js.Undefined || ko == nil {
+		return nil
+	}
+	model = ko.Call("dataFor", js.Global.Get("document").Get("body"))
+	if model == js.Undefined || model == nil || !isJSFunc(model.Get("allow_edit")) {
+		return nil
+	}
+	return model
+}
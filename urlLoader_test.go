@@ -0,0 +1,33 @@
+package freeboard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseURLParams(t *testing.T) {
+	for _, tc := range []struct {
+		hash, query string
+		want        URLParams
+	}{
+		{"", "", URLParams{Vars: map[string]string{}}},
+		{"#source=b.json", "?source=a.json&var-host=db1", URLParams{Source: "b.json", Vars: map[string]string{"host": "db1"}}},
+		{"var-host=db2", "var-host=db1&var-env=prod", URLParams{Vars: map[string]string{"host": "db2", "env": "prod"}}},
+		{"", "?edit", URLParams{Mode: "edit", Vars: map[string]string{}}},
+		{"", "?edit=no", URLParams{Mode: "view", Vars: map[string]string{}}},
+		{"", "?mode=view&edit=true", URLParams{Mode: "view", Vars: map[string]string{}}},
+		{"", "?edit=true&mode=view", URLParams{Mode: "view", Vars: map[string]string{}}},
+		{"", "?edit=true&mode=sideways", URLParams{Mode: "edit", Vars: map[string]string{}}},
+		{"#edit=true", "?mode=view", URLParams{Mode: "edit", Vars: map[string]string{}}},
+		{"#mode=view", "?mode=edit&edit=true", URLParams{Mode: "view", Vars: map[string]string{}}},
+		{"", "?mode=edit&mode=view", URLParams{Mode: "view", Vars: map[string]string{}}},
+	} {
+		// Map order varies from run to run, so try a few times.
+		for i := 0; i < 20; i++ {
+			if got := ParseURLParams(tc.hash, tc.query); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseURLParams(%q, %q) = %+v, want %+v", tc.hash, tc.query, got, tc.want)
+				break
+			}
+		}
+	}
+}
@@ -1,14 +1,17 @@
 package freeboard
 
 import (
+	"reflect"
+
 	"github.com/gopherjs/gopherjs/js"
 	"honnef.co/go/js/dom"
 )
 
 // WidgetPlugin is the interface expected of a prepared widget.
 type WidgetPlugin interface {
-	// Called when new settings are given.
-	OnSettingsChanged(map[string]interface{})
+	// Called when new settings are given. As with the settings
+	// given to NewInstance, these are freeboard's own settings object.
+	OnSettingsChanged(*js.Object)
 
 	// A public function we must implement that will be called when
 	// a calculated value changes. Since calculated values can change
@@ -24,7 +27,8 @@ type WidgetPlugin interface {
 	Render(containerElement dom.HTMLElement)
 
 	// How many 45-pixel blocks does this widget expect to be when
-	// render is called?
+	// render is called? If this changes later, call UpdateWidgetHeight
+	// so that freeboard asks again.
 	GetHeight() int
 
 	// A public function we must implement that
@@ -34,20 +38,44 @@ type WidgetPlugin interface {
 	OnDispose()
 }
 
-// WrapWidgetPlugin converts the exported function set of a DsPlugin
-// into a map consisting of the required JS function set, with
-// the other DsPlugin methods presented with lowercase leading
-// characters in the JS style. Also present is "plugin", which
-// directly references the DsPlugin object.
+// WrapWidgetPlugin converts the exported function set of a WidgetPlugin
+// into a map consisting of the JS function set freeboard calls, with
+// the WidgetPlugin methods presented with lowercase leading characters
+// in the JS style. Also present is "plugin", which directly references
+// the WidgetPlugin object.
+// Panics in any of the wrapped methods are recovered and logged to the
+// console, and the error is shown in the widget in place of its content.
 func WrapWidgetPlugin(wt WidgetPlugin) map[string]interface{} {
-	return map[string]interface{}{
+	return wrapWidgetPlugin(wt, newPluginGuard(reflect.TypeOf(wt).String()))
+}
+
+func wrapWidgetPlugin(wt WidgetPlugin, guard *pluginGuard) map[string]interface{} {
+	guard.onPanic = append(guard.onPanic, guard.showInContainer)
+	wrapper := map[string]interface{}{
 		"plugin":                   wt,
 		"onSettingsChanged":        wt.OnSettingsChanged,
-		"OnCalculatedValueChanged": wt.OnCalculatedValueChanged,
+		"onCalculatedValueChanged": wt.OnCalculatedValueChanged,
 		"render":                   jsWrap(wt.Render),
 		"getHeight":                wt.GetHeight,
 		"onDispose":                wt.OnDispose,
 	}
+	if rw, ok := wt.(ResizableWidget); ok {
+		wrapper["onSizeChanged"] = rw.OnSizeChanged
+	}
+	// Panics in the Draw hook of an embedded BaseWidget are reported
+	// under the widget's name, like those in its other methods.
+	if bh, ok := wt.(baseWidgetHolder); ok {
+		if bw := bh.baseWidget(); bw != nil {
+			bw.useGuard(guard)
+		}
+	}
+	guard.guardFuncs(wrapper)
+	return wrapper
+}
+
+// baseWidgetHolder is satisfied by widgets embedding a *BaseWidget.
+type baseWidgetHolder interface {
+	baseWidget() *BaseWidget
 }
 
 // WtPluginDefinition is a Widget Plugin
@@ -80,32 +108,65 @@ type WtPluginDefinition struct {
 	// NewInstance is called to create a new plugin. This is the
 	// Go wrapper. It is passed the calculated settings based on
 	// the definition's settings array, as a js.Object; this should
-	// be retained. It is also passed one special function, which
-	// is a simple go wrapper around the updateCallback function
-	// given by the FreeBoard NewInstance function.
+	// be retained. Later settings arrive the same way, through
+	// WidgetPlugin.OnSettingsChanged.
 	// Notably absent is NewInstanceCallback; this is handled under
 	// the Go layer for you. All you have to do is return the
-	// prepared DsPlugin-interfacing plugin object.
+	// prepared WidgetPlugin-interfacing plugin object.
 	NewInstance func(settings *js.Object) WidgetPlugin
+
+	// NewHostedInstance may be given instead of NewInstance, for widgets
+	// that need the Host they're loaded into. Taking the Host as a
+	// dependency, rather than using FB, lets them be tested with a fake
+	// host.
+	NewHostedInstance func(host Host, settings *js.Object) WidgetPlugin
 }
 
-// ToFBInterface returns a map for FreeBoard's loadDatasourcePlugin func.
+// ToFBInterface returns a map for FreeBoard's loadWidgetPlugin func.
+// Widgets are given DefaultHost; FBWrapper.AddWidgetPlugin uses
+// ToFBInterfaceFor instead, to give them the FBWrapper.
 func (wtp WtPluginDefinition) ToFBInterface() map[string]interface{} {
+	return wtp.ToFBInterfaceFor(nil)
+}
+
+// ToFBInterfaceFor is ToFBInterface for widgets loaded into host. A nil
+// host means DefaultHost, as it is when each instance is made.
+func (wtp WtPluginDefinition) ToFBInterfaceFor(host Host) map[string]interface{} {
 	output := make(map[string]interface{})
 	output["type_name"] = wtp.TypeName
 	output["display_name"] = wtp.DisplayName
 	output["description"] = wtp.Description
 	output["fill_size"] = wtp.FillSize
-	output["external_scripts"] = wtp.ExternalScripts
-	output["settings"] = make([]map[string]interface{}, 0, len(wtp.Settings))
-	settingSlice := output["settings"].([]map[string]interface{})
+	// Exposing an empty array for ExternalScripts breaks freeboard plugins.
+	if len(wtp.ExternalScripts) > 0 {
+		output["external_scripts"] = wtp.ExternalScripts
+	}
+	settingSlice := make([]map[string]interface{}, 0, len(wtp.Settings))
 	for _, s := range wtp.Settings {
 		settingSlice = append(settingSlice, s.ToFBInterface())
 	}
+	output["settings"] = settingSlice
 	output["newInstance"] = func(settings, newInstanceCallback *js.Object) {
-		Plugin := wtp.NewInstance(settings)
-		wrapper := WrapWidgetPlugin(Plugin)
+		guard := newPluginGuard(wtp.TypeName)
+		defer guard.recover("newInstance")
+		var Plugin WidgetPlugin
+		if wtp.NewHostedInstance != nil {
+			host := host
+			if host == nil {
+				host = defaultHost()
+			}
+			Plugin = wtp.NewHostedInstance(host, settings)
+		} else {
+			Plugin = wtp.NewInstance(settings)
+		}
+		wrapper := wrapWidgetPlugin(Plugin, guard)
+		onDispose := wrapper["onDispose"].(func())
+		wrapper["onDispose"] = func() {
+			onDispose()
+			emitEvent(EventData{Event: EventPluginDisposed, Kind: PluginKindWidget, TypeName: wtp.TypeName})
+		}
 		newInstanceCallback.Invoke(wrapper)
+		emitEvent(EventData{Event: EventPluginCreated, Kind: PluginKindWidget, TypeName: wtp.TypeName})
 	}
 	return output
 }
@@ -0,0 +1,166 @@
+//go:build js
+// +build js
+
+package freeboard
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// bindingWidget notes each call freeboard makes, with its arguments.
+type bindingWidget struct {
+	settings  *js.Object
+	container dom.HTMLElement
+	calls     []string
+	values    map[string]interface{}
+}
+
+func (bw *bindingWidget) OnSettingsChanged(settings *js.Object) {
+	bw.settings = settings
+	bw.calls = append(bw.calls, "OnSettingsChanged")
+}
+
+func (bw *bindingWidget) OnCalculatedValueChanged(settingName string, newValue interface{}) {
+	bw.values[settingName] = newValue
+	bw.calls = append(bw.calls, "OnCalculatedValueChanged")
+}
+
+func (bw *bindingWidget) Render(containerElement dom.HTMLElement) {
+	bw.container = containerElement
+	bw.calls = append(bw.calls, "Render")
+}
+
+func (bw *bindingWidget) GetHeight() int {
+	bw.calls = append(bw.calls, "GetHeight")
+	return 3
+}
+
+func (bw *bindingWidget) OnDispose() {
+	bw.calls = append(bw.calls, "OnDispose")
+}
+
+// resizableBindingWidget is a bindingWidget that's a ResizableWidget.
+type resizableBindingWidget struct {
+	*bindingWidget
+}
+
+func (rw resizableBindingWidget) OnSizeChanged() {
+	rw.calls = append(rw.calls, "OnSizeChanged")
+}
+
+// loadedWidgetPlugin loads def through FBWrapper into a fake freeboard,
+// and returns the plugin object freeboard was given.
+func loadedWidgetPlugin(t *testing.T, def WtPluginDefinition) *js.Object {
+	var plugin *js.Object
+	fake := js.Global.Get("Object").New()
+	fake.Set("loadWidgetPlugin", func(p *js.Object) { plugin = p })
+	fb := &FBWrapper{FreeboardObject: fake}
+	if err := fb.AddWidgetPlugin(def); err != nil {
+		t.Fatal(err)
+	}
+	if plugin == nil {
+		t.Fatal("freeboard wasn't given the plugin")
+	}
+	return plugin
+}
+
+func TestWidgetBinding(t *testing.T) {
+	for _, resizable := range []bool{false, true} {
+		var made *bindingWidget
+		def := WtPluginDefinition{
+			TypeName:    "binding_test",
+			DisplayName: "Binding test",
+			Settings: []FBSetting{
+				FBSetting{Name: "title", Type: SettingTextType, DefaultStringValue: "Untitled"},
+				FBSetting{Name: "value", Type: SettingCalculatedType},
+			},
+			NewInstance: func(settings *js.Object) WidgetPlugin {
+				made = &bindingWidget{settings: settings, values: make(map[string]interface{})}
+				if resizable {
+					return resizableBindingWidget{made}
+				}
+				return made
+			},
+		}
+		plugin := loadedWidgetPlugin(t, def)
+		if name := plugin.Get("type_name").String(); name != "binding_test" {
+			t.Errorf("type_name is %q", name)
+		}
+		settingDefs := plugin.Get("settings")
+		if settingDefs.Length() != 2 || settingDefs.Index(0).Get("name").String() != "title" ||
+			settingDefs.Index(0).Get("default_value").String() != "Untitled" || settingDefs.Index(1).Get("type").String() != "calculated" {
+			t.Errorf("settings are %s", js.Global.Get("JSON").Call("stringify", settingDefs))
+		}
+
+		// Make an instance and call each of its methods, as freeboard does.
+		settings := js.Global.Get("JSON").Call("parse", `{"title": "Cats"}`)
+		var instance *js.Object
+		plugin.Call("newInstance", settings, func(i *js.Object) { instance = i })
+		if instance == nil || made == nil {
+			t.Fatal("newInstance didn't make an instance")
+		}
+		if made.settings != settings {
+			t.Error("NewInstance wasn't given freeboard's settings object")
+		}
+		want := []string{"OnSettingsChanged", "OnCalculatedValueChanged", "Render", "GetHeight", "OnDispose"}
+		keys := []string{"onSettingsChanged", "onCalculatedValueChanged", "render", "getHeight", "onDispose"}
+		if resizable {
+			want = append(want, "OnSizeChanged")
+			keys = append(keys, "onSizeChanged")
+		} else if instance.Get("onSizeChanged") != js.Undefined {
+			t.Error("a widget that isn't resizable has onSizeChanged")
+		}
+		for _, key := range keys {
+			if instance.Get(key) == js.Undefined {
+				t.Errorf("the instance has no %s", key)
+			}
+		}
+
+		changed := js.Global.Get("JSON").Call("parse", `{"title": "Lions", "value": "datasources.cats"}`)
+		instance.Call("onSettingsChanged", changed)
+		instance.Call("onCalculatedValueChanged", "value", 42)
+		container := js.Global.Get("Object").New()
+		instance.Call("render", container)
+		height := instance.Call("getHeight").Int()
+		instance.Call("onDispose")
+		if resizable {
+			instance.Call("onSizeChanged")
+		}
+
+		if !reflect.DeepEqual(made.calls, want) {
+			t.Errorf("resizable %v: calls were %q, want %q", resizable, made.calls, want)
+		}
+		if made.settings != changed || made.settings.Get("title").String() != "Lions" {
+			t.Error("OnSettingsChanged wasn't given freeboard's new settings object")
+		}
+		if v := made.values["value"]; v != float64(42) {
+			t.Errorf("OnCalculatedValueChanged was given %v (%T), want 42", v, v)
+		}
+		if made.container == nil || made.container.Underlying() != container {
+			t.Error("Render wasn't given the container")
+		}
+		if height != 3 {
+			t.Errorf("getHeight gave %d, want 3", height)
+		}
+	}
+}
+
+func TestLoadGoWidgetPlugin(t *testing.T) {
+	def := WtPluginDefinition{
+		TypeName:    "deprecated_test",
+		NewInstance: func(*js.Object) WidgetPlugin { return &bindingWidget{} },
+	}
+	var plugin *js.Object
+	fake := js.Global.Get("Object").New()
+	fake.Set("loadWidgetPlugin", func(p *js.Object) { plugin = p })
+	(&FBWrapper{FreeboardObject: fake}).LoadGoWidgetPlugin(def)
+	if plugin == nil || plugin.Get("type_name").String() != "deprecated_test" {
+		t.Error("freeboard wasn't given the plugin")
+	}
+	// Without freeboard the error is logged, as it has nowhere to go.
+	(&FBWrapper{}).LoadGoWidgetPlugin(def)
+}
@@ -0,0 +1,44 @@
+package freeboard
+
+import (
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// ResizableWidget is a WidgetPlugin that wants to know when its size may
+// have changed, e.g. when the window is resized or the board's column
+// layout changes. Freeboard calls OnSizeChanged some time after the
+// change, once the new layout has settled.
+type ResizableWidget interface {
+	WidgetPlugin
+	OnSizeChanged()
+}
+
+// UpdateWidgetHeight asks freeboard to call GetHeight again for the
+// widget rendered into container, and to lay out its pane to suit. Use
+// it when a widget's height depends on its data. It does nothing if the
+// container isn't part of a freeboard board.
+func UpdateWidgetHeight(container dom.HTMLElement) {
+	defer func() { recover() }()
+	ko := js.Global.Get("ko")
+	if container == nil || ko == js.Undefined || ko == nil {
+		return
+	}
+	// Freeboard renders each widget into an element bound to its
+	// WidgetModel, within a PaneModel.
+	ctx := ko.Call("contextFor", container.Underlying())
+	if ctx == js.Undefined || ctx == nil {
+		return
+	}
+	if widget := ctx.Get("$data"); isJSFunc(widget.Get("_heightUpdate")) {
+		widget.Get("_heightUpdate").Call("valueHasMutated")
+	}
+	if pane := ctx.Get("$parent"); pane != js.Undefined && pane != nil && isJSFunc(pane.Get("processSizeChange")) {
+		pane.Call("processSizeChange")
+	}
+}
+
+// isJSFunc reports whether o is a JS function.
+func isJSFunc(o *js.Object) bool {
+	return o != js.Undefined && o != nil && js.Global.Get("Function").Get("prototype").Call("isPrototypeOf", o).Bool()
+}
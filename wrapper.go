@@ -1,13 +1,21 @@
 package freeboard
 
 import (
+	"encoding/json"
+	"errors"
+	"time"
+
 	"github.com/gopherjs/gopherjs/js"
 	"honnef.co/go/js/dom"
 )
 
-// FBWrapper is a wrapper for the Freeboard object.
+// FBWrapper is a wrapper for the Freeboard object, and the Host
+// for a board in the browser.
 // Use the "FB" variable instantiated at initialisation.
 type FBWrapper struct {
+	// FreeboardObject is the freeboard global. If freeboard's script
+	// hadn't loaded when this package was initialised, it's looked up
+	// again when first needed.
 	FreeboardObject *js.Object
 }
 
@@ -15,70 +23,247 @@ type FBWrapper struct {
 var FB *FBWrapper
 
 func init() {
+	// Outside a browser, e.g. a server reading Dashboards, there's no
+	// freeboard to wrap, and FB is left nil.
+	if js.Global == nil {
+		return
+	}
 	fbobj := js.Global.Get("freeboard")
 	FB = &FBWrapper{fbobj}
 }
 
+// ErrNoFreeboard is returned when freeboard's script hasn't been loaded.
+var ErrNoFreeboard = errors.New("freeboard: freeboard is not loaded")
+
+// object returns the freeboard global, looking it up if it wasn't there
+// before, or ErrNoFreeboard if it still isn't.
+func (fb *FBWrapper) object() (*js.Object, error) {
+	if o := fb.FreeboardObject; o != nil && o != js.Undefined {
+		return o, nil
+	}
+	if js.Global == nil {
+		return nil, ErrNoFreeboard
+	}
+	o := js.Global.Get("freeboard")
+	if o == nil || o == js.Undefined {
+		return nil, ErrNoFreeboard
+	}
+	fb.FreeboardObject = o
+	return o, nil
+}
+
+// call calls one of freeboard's methods.
+func (fb *FBWrapper) call(method string, args ...interface{}) (*js.Object, error) {
+	o, err := fb.object()
+	if err != nil {
+		return nil, err
+	}
+	return o.Call(method, args...), nil
+}
+
+// callJS calls one of freeboard's methods for the methods that take and
+// return JS objects, which have no error to return: if freeboard isn't
+// loaded, the error is logged and nil returned.
+func (fb *FBWrapper) callJS(method string, args ...interface{}) *js.Object {
+	o, err := fb.call(method, args...)
+	if err != nil {
+		logError(method, err.Error())
+	}
+	return o
+}
+
+// Available reports whether freeboard's script has been loaded.
+func (fb *FBWrapper) Available() bool {
+	_, err := fb.object()
+	return err == nil
+}
+
+// WaitForFreeboard waits for freeboard's script to load, e.g. when it's
+// loaded after this package's, and returns FB once it has. It returns
+// ErrNoFreeboard if it doesn't load within timeout, or if there's no
+// browser. Call it from a goroutine, such as main.
+func WaitForFreeboard(timeout time.Duration) (*FBWrapper, error) {
+	if FB == nil {
+		return nil, ErrNoFreeboard
+	}
+	deadline := time.Now().Add(timeout)
+	for !FB.Available() {
+		if time.Now().After(deadline) {
+			return nil, ErrNoFreeboard
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return FB, nil
+}
+
+// logIfError logs err, if it isn't nil, for the methods that have no
+// error to return.
+func logIfError(method string, err error) {
+	if err != nil {
+		logError(method, err.Error())
+	}
+}
+
 // Initialize is called with:
 //  * allowEdit (whether to permit the board to be edited)
 //  * finished (callback when loading is finished)
+// If Persist has been called, the saved board is restored before
+// finished is called.
+//
+// Deprecated: use InitializeBoard, which returns ErrNoFreeboard rather
+// than logging it.
 func (fb *FBWrapper) Initialize(allowEdit bool, finished func()) {
-	fb.FreeboardObject.Call("initialize", allowEdit, finished)
+	logIfError("initialize", fb.InitializeBoard(allowEdit, finished))
+}
+
+// InitializeBoard initialises freeboard, as Initialize does, and calls
+// finished when loading is finished.
+func (fb *FBWrapper) InitializeBoard(allowEdit bool, finished func()) error {
+	if p := persistenceFor(fb); p != nil {
+		_, err := fb.call("initialize", allowEdit, func() { p.restore(finished) })
+		return err
+	}
+	_, err := fb.call("initialize", allowEdit, finished)
+	return err
 }
 
 // NewDashboard clears the contents of the freeboard and initialises a new dashboard
+//
+// Deprecated: use ClearDashboard, which returns ErrNoFreeboard rather
+// than logging it.
 func (fb *FBWrapper) NewDashboard() {
-	fb.FreeboardObject.Call("newDashboard")
+	logIfError("newDashboard", fb.ClearDashboard())
+}
+
+// ClearDashboard clears the contents of the freeboard and initialises a
+// new dashboard.
+func (fb *FBWrapper) ClearDashboard() error {
+	_, err := fb.call("newDashboard")
+	return err
 }
 
 // Serialize returns a serialised object of the current board.
+// SerializeDashboard returns it as a Dashboard.
 func (fb *FBWrapper) Serialize() *js.Object {
-	return fb.FreeboardObject.Call("serialize")
+	return fb.callJS("serialize")
 }
 
 // LoadDashboard accepts a serialised dashboard and a callback for when loading completes.
+// LoadGoDashboard loads a Dashboard.
 func (fb *FBWrapper) LoadDashboard(serialised, callback *js.Object) {
-	fb.FreeboardObject.Call("loadDashboard", serialised, callback)
+	fb.callJS("loadDashboard", serialised, callback)
+}
+
+// SerializeDashboard returns the current board as a Dashboard.
+func (fb *FBWrapper) SerializeDashboard() (*Dashboard, error) {
+	serialised, err := fb.call("serialize")
+	if err != nil {
+		return nil, err
+	}
+	return ParseDashboard([]byte(js.Global.Get("JSON").Call("stringify", serialised).String()))
 }
 
-// SetEditing programmatically controls the editing state of the board
+// LoadGoDashboard loads a Dashboard, calling callback, which may be nil,
+// once loading completes.
+func (fb *FBWrapper) LoadGoDashboard(d *Dashboard, callback func()) error {
+	serialised, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return fb.loadJSON(string(serialised), callback)
+}
+
+// loadJSON loads a board serialised as JSON.
+func (fb *FBWrapper) loadJSON(serialised string, callback func()) error {
+	_, err := fb.call("loadDashboard", js.Global.Get("JSON").Call("parse", serialised), callback)
+	return err
+}
+
+// SetEditing programmatically controls the editing state of the board.
+//
+// Deprecated: use SetEditMode, which takes Go bools.
 func (fb *FBWrapper) SetEditing(editing, animate *js.Object) {
-	fb.FreeboardObject.Call("setEditing", editing, animate)
+	fb.callJS("setEditing", editing, animate)
+}
+
+// SetEditMode puts the board into, or out of, the edit state.
+func (fb *FBWrapper) SetEditMode(editing, animate bool) error {
+	_, err := fb.call("setEditing", editing, animate)
+	return err
 }
 
 // IsEditing returns boolean depending on whether the dashboard is in
 // the view-only or edit state.
+//
+// Deprecated: use EditMode, which returns a Go bool.
 func (fb *FBWrapper) IsEditing() *js.Object {
-	return fb.FreeboardObject.Call("isEditing")
+	return fb.callJS("isEditing")
+}
+
+// EditMode reports whether the board is in the edit state.
+func (fb *FBWrapper) EditMode() (bool, error) {
+	editing, err := fb.call("isEditing")
+	if err != nil {
+		return false, err
+	}
+	return editing.Bool(), nil
 }
 
 // LoadDatasourcePlugin accepts a datasource plugin and loads it.
 func (fb *FBWrapper) LoadDatasourcePlugin(ds *js.Object) {
-	fb.FreeboardObject.Call("loadDatasourcePlugin", ds)
+	fb.callJS("loadDatasourcePlugin", ds)
 }
 
 // LoadGoDatasourcePlugin accepts a datasource plugin
 // written in Go and loads it.
+//
+// Deprecated: use AddDatasourcePlugin, which returns ErrNoFreeboard
+// rather than logging it.
 func (fb *FBWrapper) LoadGoDatasourcePlugin(ds DsPluginDefinition) {
-	fb.FreeboardObject.Call("loadDatasourcePlugin", ds.ToFBInterface())
+	logIfError("loadDatasourcePlugin", fb.AddDatasourcePlugin(ds))
+}
+
+// AddDatasourcePlugin loads a datasource plugin written in Go. The
+// plugin is given this FBWrapper as its Host.
+func (fb *FBWrapper) AddDatasourcePlugin(ds DsPluginDefinition) error {
+	_, err := fb.call("loadDatasourcePlugin", ds.ToFBInterfaceFor(fb))
+	return err
 }
 
 // LoadWidgetPlugin accepts a widget plugin and loads it.
 // This can be passed either a *js.Object for a JS plugin, or a
 // map defining a Go plugin; but use LoadGoWidgetPlugin for that.
 func (fb *FBWrapper) LoadWidgetPlugin(wt *js.Object) {
-	fb.FreeboardObject.Call("loadWidgetPlugin", wt)
+	fb.callJS("loadWidgetPlugin", wt)
 }
 
 // LoadGoWidgetPlugin accepts a widget plugin written in Go
 // and loads it.
+//
+// Deprecated: use AddWidgetPlugin, which returns ErrNoFreeboard rather
+// than logging it.
 func (fb *FBWrapper) LoadGoWidgetPlugin(wt WtPluginDefinition) {
-	fb.FreeboardObject.Call("loadWidgetPlugin", wt.ToFBInterface())
+	logIfError("loadWidgetPlugin", fb.AddWidgetPlugin(wt))
+}
+
+// AddWidgetPlugin loads a widget plugin written in Go. The widget is
+// given this FBWrapper as its Host.
+func (fb *FBWrapper) AddWidgetPlugin(wt WtPluginDefinition) error {
+	_, err := fb.call("loadWidgetPlugin", wt.ToFBInterfaceFor(fb))
+	return err
 }
 
 // ShowLoadingIndicator shows or hides the loading indicator.
+//
+// Deprecated: use ShowLoading, which takes a Go bool.
 func (fb *FBWrapper) ShowLoadingIndicator(show *js.Object) {
-	fb.FreeboardObject.Call("showLoadingIndicator", show)
+	fb.callJS("showLoadingIndicator", show)
+}
+
+// ShowLoading shows or hides the loading indicator.
+func (fb *FBWrapper) ShowLoading(show bool) error {
+	_, err := fb.call("showLoadingIndicator", show)
+	return err
 }
 
 // ShowDialog shows a styled dialog box with custom content.
@@ -87,24 +272,64 @@ func (fb *FBWrapper) ShowLoadingIndicator(show *js.Object) {
 //     * okButtonTitle (string) - The string to display in the button that will be used as the OK button. A null or undefined value will result in no button being displayed.
 //     * cancelButtonTitle (string) - The string to display in the button that will be used as the Cancel button. A null or undefined value will result in no button being displayed.
 //     * okCallback (function) - A function that will be called if the user presses the OK button.
+//
+// Deprecated: use OpenDialog, which returns ErrNoFreeboard rather than
+// logging it.
 func (fb *FBWrapper) ShowDialog(contentElement dom.HTMLElement, title, okButtonTitle, cancelButtonTitle string, okCallback interface{}) {
-	fb.FreeboardObject.Call("showDialog", contentElement, title, okButtonTitle, cancelButtonTitle, okCallback)
+	logIfError("showDialog", fb.OpenDialog(contentElement, title, okButtonTitle, cancelButtonTitle, okCallback))
+}
+
+// OpenDialog shows a styled dialog box with custom content, as
+// ShowDialog does.
+func (fb *FBWrapper) OpenDialog(contentElement dom.HTMLElement, title, okButtonTitle, cancelButtonTitle string, okCallback interface{}) error {
+	_, err := fb.call("showDialog", contentElement, title, okButtonTitle, cancelButtonTitle, okCallback)
+	return err
 }
 
 // GetDatasourceSettings returns the current settings for a datasource
 // or null if no datasource with the given name is found.
+//
+// Deprecated: use DatasourceSettings, which returns them as a Go map.
 func (fb *FBWrapper) GetDatasourceSettings(name string) *js.Object {
-	return fb.FreeboardObject.Call("getDatasourceSettings", name)
+	return fb.callJS("getDatasourceSettings", name)
+}
+
+// DatasourceSettings returns the current settings of a datasource, or
+// nil if there's no datasource of that name.
+func (fb *FBWrapper) DatasourceSettings(name string) (map[string]interface{}, error) {
+	settings, err := fb.call("getDatasourceSettings", name)
+	if err != nil || settings == nil || settings == js.Undefined {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(js.Global.Get("JSON").Call("stringify", settings).String()), &m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
 // SetDatasourceSettings updates settings on a datasource.
+//
+// Deprecated: use UpdateDatasourceSettings, which takes them as a Go
+// map.
 func (fb *FBWrapper) SetDatasourceSettings(name string, settings *js.Object) {
-	fb.FreeboardObject.Call("setDatasourceSettings", name, settings)
+	fb.callJS("setDatasourceSettings", name, settings)
+}
+
+// UpdateDatasourceSettings updates settings on a datasource.
+func (fb *FBWrapper) UpdateDatasourceSettings(name string, settings map[string]interface{}) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	_, err = fb.call("setDatasourceSettings", name, js.Global.Get("JSON").Call("parse", string(data)))
+	return err
 }
 
 // On attaches a callback to a global freeboard event.
 // At present, only "dashboard_loaded" and "initialized" are
-// fired as events by freeboard.
+// fired as events by freeboard. Subscribe takes Go funcs, and
+// can be unsubscribed.
 func (fb *FBWrapper) On(eventName string, callback *js.Object) {
-	fb.FreeboardObject.Call("on", eventName, callback)
+	fb.callJS("on", eventName, callback)
 }